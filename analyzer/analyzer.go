@@ -76,12 +76,24 @@ func (a *Analyzer) StartAnalysis(ctx context.Context, currentID, previousID int6
 		return nil, fmt.Errorf("current snapshot %d not found", currentID)
 	}
 
-	previousSnapshot, err := a.snapshots.GetByID(ctx, previousID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get previous snapshot: %w", err)
-	}
-	if previousSnapshot == nil {
-		return nil, fmt.Errorf("previous snapshot %d not found", previousID)
+	var previousSnapshot *models.Snapshot
+	if previousID == 0 {
+		previousSnapshot, err = a.snapshots.GetComparison(ctx, currentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comparison snapshot: %w", err)
+		}
+		if previousSnapshot == nil {
+			return nil, fmt.Errorf("no baseline or previous snapshot to compare snapshot %d with", currentID)
+		}
+		previousID = previousSnapshot.ID
+	} else {
+		previousSnapshot, err = a.snapshots.GetByID(ctx, previousID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get previous snapshot: %w", err)
+		}
+		if previousSnapshot == nil {
+			return nil, fmt.Errorf("previous snapshot %d not found", previousID)
+		}
 	}
 
 	existing, err := a.analysisRepo.GetByPair(ctx, currentID, previousID)
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/illenko/whodidthis/storage"
+)
+
+type AdminHandler struct {
+	db         *storage.DB
+	backupDir  string
+	backupKeep int
+}
+
+// NewAdminHandler takes the backup directory and rotation of storage.backup; backupDir may be
+// empty, which leaves only backup downloads.
+func NewAdminHandler(db *storage.DB, backupDir string, backupKeep int) *AdminHandler {
+	return &AdminHandler{
+		db:         db,
+		backupDir:  backupDir,
+		backupKeep: backupKeep,
+	}
+}
+
+// Backup writes a backup of the running database into the backup directory and rotates old ones.
+func (h *AdminHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	if h.backupDir == "" {
+		writeError(w, http.StatusBadRequest, "storage.backup.dir is not configured, use GET /api/admin/backup to download a backup")
+		return
+	}
+
+	// VACUUM INTO of a large database takes longer than the server's write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Warn("failed to clear write deadline for backup", "error", err)
+	}
+
+	start := time.Now()
+	path, err := h.db.BackupToDir(r.Context(), h.backupDir, h.backupKeep)
+	if err != nil {
+		writeBackupError(w, err)
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	slog.Info("backup completed", "file", path, "duration", time.Since(start))
+	writeJSON(w, http.StatusOK, map[string]any{"path": path, "size_bytes": size})
+}
+
+// DownloadBackup streams a backup of the running database.
+func (h *AdminHandler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	tmpDir, err := os.MkdirTemp("", "whodidthis-backup-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Both writing the backup and sending it can take longer than the server's write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Warn("failed to clear write deadline for backup download", "error", err)
+	}
+
+	path := filepath.Join(tmpDir, "whodidthis.db")
+	if err := h.db.Backup(r.Context(), path); err != nil {
+		writeBackupError(w, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("whodidthis-%s.db", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		slog.Error("failed to send backup", "error", err)
+	}
+}
+
+func writeBackupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrBackupUnsupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
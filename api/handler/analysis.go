@@ -33,8 +33,9 @@ func (a *AnalysisHandler) Start(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	if req.CurrentSnapshotID == 0 || req.PreviousSnapshotID == 0 {
-		writeError(w, http.StatusBadRequest, "current_snapshot_id and previous_snapshot_id are required")
+	// Without previous_snapshot_id the analyzer compares with the default baseline or the previous scan.
+	if req.CurrentSnapshotID == 0 {
+		writeError(w, http.StatusBadRequest, "current_snapshot_id is required")
 		return
 	}
 
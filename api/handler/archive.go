@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/illenko/whodidthis/archive"
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+type ArchiveHandler struct {
+	repo      storage.ArchiveRepo
+	snapshots storage.SnapshotsRepo
+}
+
+func NewArchiveHandler(repo storage.ArchiveRepo, snapshots storage.SnapshotsRepo) *ArchiveHandler {
+	return &ArchiveHandler{
+		repo:      repo,
+		snapshots: snapshots,
+	}
+}
+
+// Export downloads the scans in the comma-separated ids parameter as a json (default) or
+// ndjson archive.
+func (h *ArchiveHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var ids []int64
+	for _, s := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid scan id "+s)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		writeError(w, http.StatusBadRequest, "ids is required")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = archive.FormatJSON
+	}
+	if !archive.ValidFormat(format) {
+		writeError(w, http.StatusBadRequest, "format must be json or ndjson")
+		return
+	}
+
+	// Check the scans up front; once the archive is streaming, errors can only be logged.
+	for _, id := range ids {
+		snapshot, err := h.snapshots.GetByID(ctx, id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if snapshot == nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("scan %d not found", id))
+			return
+		}
+		if snapshot.Status == models.ScanRunStatusRunning {
+			writeError(w, http.StatusConflict, fmt.Sprintf("scan %d is still running", id))
+			return
+		}
+	}
+
+	// Archives of many scans take longer to write than the server's write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Warn("failed to clear write deadline for export", "error", err)
+	}
+
+	contentType := "application/json"
+	if format == archive.FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("whodidthis-scans-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := archive.Export(ctx, h.repo, ids, format, w); err != nil {
+		slog.Error("failed to export scans", "ids", ids, "error", err)
+	}
+}
+
+// Import loads a json or ndjson archive from the request body under new scan ids.
+func (h *ArchiveHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if err := http.NewResponseController(w).SetReadDeadline(time.Time{}); err != nil {
+		slog.Warn("failed to clear read deadline for import", "error", err)
+	}
+
+	result, err := archive.Import(r.Context(), h.repo, r.Body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, archive.ErrInvalidArchive) {
+			status = http.StatusBadRequest
+		}
+		msg := err.Error()
+		if result != nil && len(result.Snapshots) > 0 {
+			msg = fmt.Sprintf("%s (%d scans imported before the error)", msg, len(result.Snapshots))
+		}
+		writeError(w, status, msg)
+		return
+	}
+
+	slog.Info("imported scans", "snapshots", len(result.Snapshots), "analyses", result.Analyses)
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/illenko/whodidthis/storage"
+)
+
+type DiffHandler struct {
+	diffRepo      storage.DiffRepo
+	snapshotsRepo storage.SnapshotsRepo
+}
+
+func NewDiffHandler(diffRepo storage.DiffRepo, snapshotsRepo storage.SnapshotsRepo) *DiffHandler {
+	return &DiffHandler{
+		diffRepo:      diffRepo,
+		snapshotsRepo: snapshotsRepo,
+	}
+}
+
+// Get diffs two snapshots. Without from, the to snapshot is compared with the default baseline
+// of its source and tenant, or else the snapshot before it.
+func (d *DiffHandler) Get(w http.ResponseWriter, r *http.Request) {
+	toID, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to parameter")
+		return
+	}
+
+	var fromID int64
+	if from := r.URL.Query().Get("from"); from != "" {
+		fromID, err = strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from parameter")
+			return
+		}
+	} else {
+		comparison, err := d.snapshotsRepo.GetComparison(r.Context(), toID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if comparison == nil {
+			writeError(w, http.StatusNotFound, "no baseline or previous scan to compare with")
+			return
+		}
+		fromID = comparison.ID
+	}
+
+	diff, err := d.diffRepo.Diff(r.Context(), fromID, toID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if diff == nil {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diff)
+}
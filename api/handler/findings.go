@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+type FindingsHandler struct {
+	findingsRepo storage.FindingsRepo
+}
+
+func NewFindingsHandler(findingsRepo storage.FindingsRepo) *FindingsHandler {
+	return &FindingsHandler{
+		findingsRepo: findingsRepo,
+	}
+}
+
+func (f *FindingsHandler) List(w http.ResponseWriter, r *http.Request) {
+	scanID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan id")
+		return
+	}
+
+	findings, err := f.findingsRepo.ListBySnapshot(r.Context(), scanID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if findings == nil {
+		findings = []models.LabelFinding{}
+	}
+
+	writeJSON(w, http.StatusOK, findings)
+}
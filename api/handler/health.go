@@ -9,18 +9,18 @@ import (
 )
 
 type HealthHandler struct {
-	snapshots  storage.SnapshotsRepo
-	db         *storage.DB
-	promClient prometheus.MetricsClient
+	snapshots   storage.SnapshotsRepo
+	db          *storage.DB
+	promClients []prometheus.MetricsClient
 }
 
 func NewHealthHandler(snapshots storage.SnapshotsRepo,
 	db *storage.DB,
-	promClient prometheus.MetricsClient) *HealthHandler {
+	promClients []prometheus.MetricsClient) *HealthHandler {
 	return &HealthHandler{
-		snapshots:  snapshots,
-		db:         db,
-		promClient: promClient,
+		snapshots:   snapshots,
+		db:          db,
+		promClients: promClients,
 	}
 }
 
@@ -38,16 +38,17 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 		status.DatabaseOK = false
 	}
 
-	if h.promClient != nil {
-		if err := h.promClient.HealthCheck(ctx); err != nil {
+	for _, client := range h.promClients {
+		if err := client.HealthCheck(ctx); err != nil {
 			status.PrometheusConnected = false
 			if status.Status == "healthy" {
 				status.Status = "degraded"
 			}
+			break
 		}
 	}
 
-	latest, _ := h.snapshots.GetLatest(ctx)
+	latest, _ := h.snapshots.GetLatest(ctx, storage.SnapshotFilter{})
 	if latest != nil {
 		status.LastScan = latest.CollectedAt
 	}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/illenko/whodidthis/storage"
+)
+
+type HistoryHandler struct {
+	servicesRepo storage.ServicesRepo
+	metricsRepo  storage.MetricsRepo
+}
+
+func NewHistoryHandler(servicesRepo storage.ServicesRepo, metricsRepo storage.MetricsRepo) *HistoryHandler {
+	return &HistoryHandler{
+		servicesRepo: servicesRepo,
+		metricsRepo:  metricsRepo,
+	}
+}
+
+func (h *HistoryHandler) Service(w http.ResponseWriter, r *http.Request) {
+	serviceName := r.PathValue("service")
+	limit := parseIntParam(r, "limit", 0)
+
+	points, err := h.servicesRepo.History(r.Context(), serviceName, parseSnapshotFilter(r), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(points) == 0 {
+		writeError(w, http.StatusNotFound, "service not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, points)
+}
+
+func (h *HistoryHandler) Metric(w http.ResponseWriter, r *http.Request) {
+	serviceName := r.PathValue("service")
+	metricName := r.PathValue("metric")
+	limit := parseIntParam(r, "limit", 0)
+
+	points, err := h.metricsRepo.History(r.Context(), serviceName, metricName, parseSnapshotFilter(r), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(points) == 0 {
+		writeError(w, http.StatusNotFound, "metric not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, points)
+}
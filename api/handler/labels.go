@@ -66,3 +66,25 @@ func (h *LabelsHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, labels)
 }
+
+func (h *LabelsHandler) ListDropSuggestions(w http.ResponseWriter, r *http.Request) {
+	scanID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan id")
+		return
+	}
+
+	limit := parseIntParam(r, "limit", 50)
+
+	suggestions, err := h.labelsRepo.ListDropSuggestions(r.Context(), scanID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if suggestions == nil {
+		suggestions = []models.DropSuggestion{}
+	}
+
+	writeJSON(w, http.StatusOK, suggestions)
+}
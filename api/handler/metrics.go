@@ -9,14 +9,16 @@ import (
 )
 
 type MetricsHandler struct {
-	servicesRepo storage.ServicesRepo
-	metricsRepo  storage.MetricsRepo
+	snapshotsRepo storage.SnapshotsRepo
+	servicesRepo  storage.ServicesRepo
+	metricsRepo   storage.MetricsRepo
 }
 
-func NewMetricsHandler(servicesRepo storage.ServicesRepo, metricsRepo storage.MetricsRepo) *MetricsHandler {
+func NewMetricsHandler(snapshotsRepo storage.SnapshotsRepo, servicesRepo storage.ServicesRepo, metricsRepo storage.MetricsRepo) *MetricsHandler {
 	return &MetricsHandler{
-		servicesRepo: servicesRepo,
-		metricsRepo:  metricsRepo,
+		snapshotsRepo: snapshotsRepo,
+		servicesRepo:  servicesRepo,
+		metricsRepo:   metricsRepo,
 	}
 }
 
@@ -93,3 +95,52 @@ func (m *MetricsHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, metric)
 }
+
+func (m *MetricsHandler) ListTop(w http.ResponseWriter, r *http.Request) {
+	scanID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan id")
+		return
+	}
+
+	opts := storage.TopMetricsOptions{
+		Sort:      r.URL.Query().Get("sort"),
+		MinSeries: parseIntParam(r, "min_series", 0),
+		Limit:     parseIntParam(r, "limit", 50),
+	}
+
+	if compare := r.URL.Query().Get("compare"); compare != "" {
+		opts.PreviousSnapshotID, err = strconv.ParseInt(compare, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid compare parameter")
+			return
+		}
+	}
+
+	if (opts.Sort == "growth" || opts.Sort == "growth_percent") && opts.PreviousSnapshotID == 0 {
+		writeError(w, http.StatusBadRequest, "compare parameter is required for growth sorting")
+		return
+	}
+
+	scan, err := m.snapshotsRepo.GetByID(r.Context(), scanID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if scan == nil {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+
+	metrics, err := m.metricsRepo.ListTop(r.Context(), scanID, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if metrics == nil {
+		metrics = []models.TopMetric{}
+	}
+
+	writeJSON(w, http.StatusOK, metrics)
+}
@@ -5,6 +5,8 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+
+	"github.com/illenko/whodidthis/storage"
 )
 
 func parseIntParam(r *http.Request, name string, defaultVal int) int {
@@ -19,6 +21,14 @@ func parseIntParam(r *http.Request, name string, defaultVal int) int {
 	return n
 }
 
+// parseSnapshotFilter reads the optional source and tenant query parameters.
+func parseSnapshotFilter(r *http.Request) storage.SnapshotFilter {
+	return storage.SnapshotFilter{
+		Source: r.URL.Query().Get("source"),
+		Tenant: r.URL.Query().Get("tenant"),
+	}
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
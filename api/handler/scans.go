@@ -1,9 +1,16 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/illenko/whodidthis/collector"
 	"github.com/illenko/whodidthis/models"
 	"github.com/illenko/whodidthis/scheduler"
 	"github.com/illenko/whodidthis/storage"
@@ -11,22 +18,30 @@ import (
 
 type ScansHandler struct {
 	repo      storage.SnapshotsRepo
+	runs      storage.ScanRunsRepo
 	scheduler *scheduler.Scheduler
 }
 
-func NewScansHandler(repo storage.SnapshotsRepo, scheduler *scheduler.Scheduler) *ScansHandler {
+func NewScansHandler(repo storage.SnapshotsRepo, runs storage.ScanRunsRepo, scheduler *scheduler.Scheduler) *ScansHandler {
 	return &ScansHandler{
 		repo:      repo,
+		runs:      runs,
 		scheduler: scheduler,
 	}
 }
 
+// scanDetail is a snapshot with the run that collected it, whose failures are narrowed to the snapshot.
+type scanDetail struct {
+	models.Snapshot
+	Run *models.ScanRun `json:"run,omitempty"`
+}
+
 func (s *ScansHandler) List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	limit := parseIntParam(r, "limit", 100)
 
-	scans, err := s.repo.List(ctx, limit)
+	scans, err := s.repo.List(ctx, parseSnapshotFilter(r), limit)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -42,7 +57,7 @@ func (s *ScansHandler) List(w http.ResponseWriter, r *http.Request) {
 func (s *ScansHandler) GetLatest(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	scan, err := s.repo.GetLatest(ctx)
+	scan, err := s.repo.GetLatest(ctx, parseSnapshotFilter(r))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -76,28 +91,237 @@ func (s *ScansHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	detail := scanDetail{Snapshot: *scan}
+	if scan.ScanRunID != 0 {
+		run, err := s.runs.GetByID(ctx, scan.ScanRunID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if run != nil {
+			run.Failures, err = s.runs.ListFailures(ctx, run.ID, scan.ID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			detail.Run = run
+		}
+	}
+
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// Pin makes the scan a named baseline, which retention cleanup keeps. A default baseline becomes
+// the comparison target of its source and tenant.
+func (s *ScansHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan id")
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Default     bool   `json:"default"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	scan, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if scan == nil {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+
+	err = s.repo.Pin(ctx, id, models.Baseline{
+		Name:        req.Name,
+		Description: req.Description,
+		Default:     req.Default,
+		PinnedAt:    time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrBaselineNameTaken) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	scan, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	writeJSON(w, http.StatusOK, scan)
 }
 
+// Unpin removes the scan's baseline, leaving it to retention cleanup again.
+func (s *ScansHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan id")
+		return
+	}
+
+	if err := s.repo.Unpin(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "unpinned"})
+}
+
+// ListBaselines returns the pinned scans.
+func (s *ScansHandler) ListBaselines(w http.ResponseWriter, r *http.Request) {
+	baselines, err := s.repo.ListBaselines(r.Context(), parseSnapshotFilter(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if baselines == nil {
+		baselines = []models.Snapshot{}
+	}
+
+	writeJSON(w, http.StatusOK, baselines)
+}
+
+// ListRuns returns recent scan runs, including failed runs that produced no snapshot.
+func (s *ScansHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limit := parseIntParam(r, "limit", 100)
+
+	runs, err := s.runs.List(ctx, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if runs == nil {
+		runs = []models.ScanRun{}
+	}
+
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// GetRun returns a scan run with the failures of all its snapshots.
+func (s *ScansHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan run id")
+		return
+	}
+
+	run, err := s.runs.GetByID(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if run == nil {
+		writeError(w, http.StatusNotFound, "scan run not found")
+		return
+	}
+
+	run.Failures, err = s.runs.ListFailures(ctx, run.ID, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+// Trigger starts a scan. An optional body names the profile to run, and services and/or
+// service_regex rescan only the matching services into a targeted snapshot.
 func (s *ScansHandler) Trigger(w http.ResponseWriter, r *http.Request) {
 	if s.scheduler == nil {
 		writeError(w, http.StatusServiceUnavailable, "scheduler not configured")
 		return
 	}
 
-	err := s.scheduler.TriggerScan()
+	var req struct {
+		Profile      string   `json:"profile"`
+		Services     []string `json:"services"`
+		ServiceRegex string   `json:"service_regex"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var filter *collector.ServiceFilter
+	if len(req.Services) > 0 || req.ServiceRegex != "" {
+		filter = &collector.ServiceFilter{Names: req.Services}
+		if req.ServiceRegex != "" {
+			re, err := regexp.Compile(req.ServiceRegex)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid service_regex: "+err.Error())
+				return
+			}
+			filter.Regex = re
+		}
+	}
+
+	err := s.scheduler.TriggerScan(req.Profile, filter)
 	if err != nil {
 		if err == scheduler.ErrScanAlreadyRunning {
 			writeError(w, http.StatusConflict, err.Error())
 			return
 		}
+		if err == scheduler.ErrUnknownProfile {
+			writeError(w, http.StatusBadRequest, err.Error()+": "+req.Profile)
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if filter != nil {
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "targeted scan started", "filter": filter.String()})
+		return
+	}
 	writeJSON(w, http.StatusAccepted, map[string]string{"status": "scan started"})
 }
 
+// Cancel stops the running scan. Snapshots it was writing are marked cancelled.
+func (s *ScansHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		writeError(w, http.StatusServiceUnavailable, "scheduler not configured")
+		return
+	}
+
+	if err := s.scheduler.CancelScan(); err != nil {
+		if err == scheduler.ErrNoScanRunning {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "scan cancelling"})
+}
+
 func (s *ScansHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	if s.scheduler == nil {
 		writeError(w, http.StatusServiceUnavailable, "scheduler not configured")
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/illenko/whodidthis/storage"
+)
+
+type TSDBHandler struct {
+	tsdbStatsRepo storage.TSDBStatsRepo
+}
+
+func NewTSDBHandler(tsdbStatsRepo storage.TSDBStatsRepo) *TSDBHandler {
+	return &TSDBHandler{
+		tsdbStatsRepo: tsdbStatsRepo,
+	}
+}
+
+func (t *TSDBHandler) Get(w http.ResponseWriter, r *http.Request) {
+	scanID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan id")
+		return
+	}
+
+	overview, err := t.tsdbStatsRepo.Get(r.Context(), scanID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if overview == nil {
+		writeError(w, http.StatusNotFound, "no tsdb status recorded for scan")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, overview)
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/illenko/whodidthis/telemetry"
 )
 
 const requestTimeout = 30 * time.Second
@@ -20,7 +23,15 @@ func (w *statusWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
-func withMiddleware(next http.Handler) http.Handler {
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift the write deadline
+// for long downloads.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withMiddleware wraps mux. Requests are cancelled after requestTimeout, except on the untimed
+// routes, which stream archives or backups and run until the client goes away.
+func withMiddleware(mux *http.ServeMux, untimed map[string]bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -36,7 +47,7 @@ func withMiddleware(next http.Handler) http.Handler {
 		}()
 
 		sw.Header().Set("Access-Control-Allow-Origin", "*")
-		sw.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		sw.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		sw.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 		if r.Method == http.MethodOptions {
@@ -44,10 +55,20 @@ func withMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-		defer cancel()
+		req := r
+		if _, pattern := mux.Handler(r); !untimed[pattern] {
+			ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+			defer cancel()
+			req = r.WithContext(ctx)
+		}
+		mux.ServeHTTP(sw, req)
 
-		next.ServeHTTP(sw, r.WithContext(ctx))
+		// req.Pattern is set by the mux and keeps the route label bounded.
+		route := req.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		telemetry.HTTPRequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(sw.status)).Observe(time.Since(start).Seconds())
 
 		slog.Debug("request completed",
 			"method", r.Method,
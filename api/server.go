@@ -28,6 +28,13 @@ func NewServer(
 	servicesHandler *handler.ServicesHandler,
 	metricsHandler *handler.MetricsHandler,
 	labelsHandler *handler.LabelsHandler,
+	diffHandler *handler.DiffHandler,
+	historyHandler *handler.HistoryHandler,
+	findingsHandler *handler.FindingsHandler,
+	tsdbHandler *handler.TSDBHandler,
+	archiveHandler *handler.ArchiveHandler,
+	adminHandler *handler.AdminHandler,
+	promHandler http.Handler,
 	cfg ServerConfig) *Server {
 	if cfg.ReadTimeout == 0 {
 		cfg.ReadTimeout = 30 * time.Second
@@ -37,14 +44,33 @@ func NewServer(
 	}
 
 	mux := http.NewServeMux()
+	untimed := make(map[string]bool)
+	handleUntimed := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, h)
+		untimed[pattern] = true
+	}
 
 	mux.HandleFunc("GET /health", healthHandler.Health)
+	mux.Handle("GET /metrics", promHandler)
 
 	mux.HandleFunc("POST /api/scan", scansHandler.Trigger)
+	mux.HandleFunc("DELETE /api/scan", scansHandler.Cancel)
 	mux.HandleFunc("GET /api/scan/status", scansHandler.GetStatus)
+	mux.HandleFunc("GET /api/scan/runs", scansHandler.ListRuns)
+	mux.HandleFunc("GET /api/scan/runs/{id}", scansHandler.GetRun)
 	mux.HandleFunc("GET /api/scans", scansHandler.List)
 	mux.HandleFunc("GET /api/scans/latest", scansHandler.GetLatest)
 	mux.HandleFunc("GET /api/scans/{id}", scansHandler.Get)
+	mux.HandleFunc("PUT /api/scans/{id}/baseline", scansHandler.Pin)
+	mux.HandleFunc("DELETE /api/scans/{id}/baseline", scansHandler.Unpin)
+	mux.HandleFunc("GET /api/baselines", scansHandler.ListBaselines)
+	handleUntimed("GET /api/scans/export", archiveHandler.Export)
+	handleUntimed("POST /api/scans/import", archiveHandler.Import)
+
+	mux.HandleFunc("GET /api/scans/{id}/metrics", metricsHandler.ListTop)
+	mux.HandleFunc("GET /api/scans/{id}/findings", findingsHandler.List)
+	mux.HandleFunc("GET /api/scans/{id}/suggestions", labelsHandler.ListDropSuggestions)
+	mux.HandleFunc("GET /api/scans/{id}/tsdb", tsdbHandler.Get)
 
 	mux.HandleFunc("GET /api/scans/{id}/services", servicesHandler.List)
 	mux.HandleFunc("GET /api/scans/{id}/services/{service}", servicesHandler.Get)
@@ -54,18 +80,29 @@ func NewServer(
 
 	mux.HandleFunc("GET /api/scans/{id}/services/{service}/metrics/{metric}/labels", labelsHandler.List)
 
+	mux.HandleFunc("GET /api/diff", diffHandler.Get)
+
+	mux.HandleFunc("GET /api/history/services/{service}", historyHandler.Service)
+	mux.HandleFunc("GET /api/history/services/{service}/metrics/{metric}", historyHandler.Metric)
+
 	mux.HandleFunc("POST /api/analysis", analysisHandler.Start)
 	mux.HandleFunc("GET /api/analysis", analysisHandler.Get)
 	mux.HandleFunc("DELETE /api/analysis", analysisHandler.Delete)
 	mux.HandleFunc("GET /api/analysis/status", analysisHandler.GetStatus)
 	mux.HandleFunc("GET /api/scans/{id}/analyses", analysisHandler.ListBySnapshot)
 
+	// The admin API has no authentication; adminHandler is nil unless server.admin_api is set.
+	if adminHandler != nil {
+		handleUntimed("POST /api/admin/backup", adminHandler.Backup)
+		handleUntimed("GET /api/admin/backup", adminHandler.DownloadBackup)
+	}
+
 	mux.Handle("/", staticHandler())
 
 	return &Server{
 		httpServer: &http.Server{
 			Addr:         cfg.Host + ":" + strconv.Itoa(cfg.Port),
-			Handler:      withMiddleware(mux),
+			Handler:      withMiddleware(mux, untimed),
 			ReadTimeout:  cfg.ReadTimeout,
 			WriteTimeout: cfg.WriteTimeout,
 		},
@@ -0,0 +1,195 @@
+// Package archive exports snapshots to versioned JSON or NDJSON archives and imports them into
+// another instance under new IDs.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+// Version is the archive format version written by Export. Import reads versions up to it.
+const Version = 1
+
+const (
+	FormatJSON   = "json"   // one document: the header fields and a snapshots array
+	FormatNDJSON = "ndjson" // the header on the first line, then one snapshot per line
+)
+
+var (
+	ErrNotFound       = errors.New("snapshot not found")
+	ErrInvalidArchive = errors.New("invalid archive")
+)
+
+// Header identifies an archive.
+type Header struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+type document struct {
+	Header
+	Snapshots []models.ArchivedSnapshot `json:"snapshots"`
+}
+
+// Result maps the snapshot IDs of an archive to the IDs they were imported under.
+type Result struct {
+	Snapshots []ImportedSnapshot `json:"snapshots"`
+	Analyses  int                `json:"analyses"`
+	// SkippedAnalyses compare with a snapshot that is not in the archive.
+	SkippedAnalyses int `json:"skipped_analyses,omitempty"`
+}
+
+type ImportedSnapshot struct {
+	ArchiveID int64 `json:"archive_id"`
+	ID        int64 `json:"id"`
+}
+
+// ValidFormat reports whether format is an archive format.
+func ValidFormat(format string) bool {
+	return format == FormatJSON || format == FormatNDJSON
+}
+
+// Export writes the snapshots with the given IDs to w. NDJSON archives are written one snapshot at
+// a time, so exporting many snapshots does not hold them all in memory.
+func Export(ctx context.Context, repo storage.ArchiveRepo, ids []int64, format string, w io.Writer) error {
+	if !ValidFormat(format) {
+		return fmt.Errorf("unknown archive format %q", format)
+	}
+	header := Header{Version: Version, ExportedAt: time.Now().UTC()}
+
+	enc := json.NewEncoder(w)
+	if format == FormatJSON {
+		doc := document{Header: header, Snapshots: make([]models.ArchivedSnapshot, 0, len(ids))}
+		for _, id := range ids {
+			s, err := load(ctx, repo, id)
+			if err != nil {
+				return err
+			}
+			doc.Snapshots = append(doc.Snapshots, *s)
+		}
+		return enc.Encode(doc)
+	}
+
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s, err := load(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func load(ctx context.Context, repo storage.ArchiveRepo, id int64) (*models.ArchivedSnapshot, error) {
+	s, err := repo.Export(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("export snapshot %d: %w", id, err)
+	}
+	if s == nil {
+		return nil, fmt.Errorf("export snapshot %d: %w", id, ErrNotFound)
+	}
+	return s, nil
+}
+
+// Import reads a JSON or NDJSON archive from r and stores its snapshots under new IDs. Analyses
+// are imported once all snapshots are, if both of their snapshots were in the archive. Each
+// snapshot is imported on its own, so on error the result lists the snapshots already imported.
+// If ctx is cancelled, the snapshots already imported are deleted again.
+func Import(ctx context.Context, repo storage.ArchiveRepo, r io.Reader) (*Result, error) {
+	result, err := importArchive(ctx, repo, r)
+	if err == nil || ctx.Err() == nil || result == nil {
+		return result, err
+	}
+
+	// The client went away or the server is shutting down; half an archive is worse than none.
+	cleanupCtx := context.WithoutCancel(ctx)
+	errs := []error{err}
+	remaining := &Result{Snapshots: []ImportedSnapshot{}}
+	for _, s := range result.Snapshots {
+		if err := repo.Delete(cleanupCtx, s.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete imported snapshot %d: %w", s.ID, err))
+			remaining.Snapshots = append(remaining.Snapshots, s)
+		}
+	}
+	return remaining, errors.Join(errs...)
+}
+
+func importArchive(ctx context.Context, repo storage.ArchiveRepo, r io.Reader) (*Result, error) {
+	dec := json.NewDecoder(r)
+
+	var doc document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: read header: %v", ErrInvalidArchive, err)
+	}
+	if doc.Version < 1 {
+		return nil, fmt.Errorf("%w: missing version", ErrInvalidArchive)
+	}
+	if doc.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d (supported up to %d)", ErrInvalidArchive, doc.Version, Version)
+	}
+
+	result := &Result{Snapshots: []ImportedSnapshot{}}
+	ids := make(map[int64]int64)
+	var analyses []models.SnapshotAnalysis
+
+	importOne := func(s *models.ArchivedSnapshot) error {
+		id, err := repo.Import(ctx, s)
+		if err != nil {
+			return fmt.Errorf("import snapshot %d: %w", s.Snapshot.ID, err)
+		}
+		ids[s.Snapshot.ID] = id
+		result.Snapshots = append(result.Snapshots, ImportedSnapshot{ArchiveID: s.Snapshot.ID, ID: id})
+		analyses = append(analyses, s.Analyses...)
+		return nil
+	}
+
+	// A JSON archive has its snapshots in the first document; an NDJSON archive has only the
+	// header there and a snapshot on each following line.
+	if doc.Snapshots != nil {
+		for i := range doc.Snapshots {
+			if err := importOne(&doc.Snapshots[i]); err != nil {
+				return result, err
+			}
+		}
+	} else {
+		for {
+			var s models.ArchivedSnapshot
+			if err := dec.Decode(&s); err == io.EOF {
+				break
+			} else if err != nil {
+				return result, fmt.Errorf("%w: read snapshot %d: %v", ErrInvalidArchive, len(result.Snapshots)+1, err)
+			}
+			if err := importOne(&s); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	for _, a := range analyses {
+		currentID, okCurrent := ids[a.CurrentSnapshotID]
+		previousID, okPrevious := ids[a.PreviousSnapshotID]
+		if !okCurrent || !okPrevious {
+			result.SkippedAnalyses++
+			continue
+		}
+		a.CurrentSnapshotID, a.PreviousSnapshotID = currentID, previousID
+		if err := repo.ImportAnalysis(ctx, &a); err != nil {
+			return result, fmt.Errorf("import analysis %d: %w", a.ID, err)
+		}
+		result.Analyses++
+	}
+
+	return result, nil
+}
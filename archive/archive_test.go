@@ -0,0 +1,247 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+var testTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func openTestDB(t *testing.T) *storage.DB {
+	t.Helper()
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testSnapshot(source string, collectedAt time.Time, series int) models.ArchivedSnapshot {
+	withoutPath := 3
+	return models.ArchivedSnapshot{
+		Snapshot: models.Snapshot{
+			Source:        source,
+			Tenant:        "team-a",
+			Status:        models.ScanRunStatusCompleted,
+			CollectedAt:   collectedAt,
+			TotalServices: 1,
+			TotalSeries:   int64(series + 1),
+		},
+		Services: []models.ArchivedService{{
+			ServiceSnapshot: models.ServiceSnapshot{ServiceName: "api", TotalSeries: series + 1, MetricCount: 2},
+			Metrics: []models.ArchivedMetric{
+				{
+					MetricSnapshot: models.MetricSnapshot{MetricName: "http_requests_total", SeriesCount: series, LabelCount: 2},
+					Labels: []models.LabelSnapshot{
+						{LabelName: "method", UniqueValuesCount: 2, SampleValues: []string{"GET", "POST"}},
+						{LabelName: "path", UniqueValuesCount: series / 2, SampleValues: []string{"/users"}, SeriesWithoutLabel: &withoutPath},
+					},
+				},
+				{MetricSnapshot: models.MetricSnapshot{MetricName: "up", SeriesCount: 1}},
+			},
+		}},
+	}
+}
+
+// seed imports the snapshots into db, with an analysis comparing the last one with the first,
+// and returns their IDs.
+func seed(t *testing.T, db *storage.DB, snapshots ...models.ArchivedSnapshot) []int64 {
+	t.Helper()
+	ctx := context.Background()
+	repo := storage.NewArchiveRepository(db)
+
+	var ids []int64
+	for i := range snapshots {
+		id, err := repo.Import(ctx, &snapshots[i])
+		if err != nil {
+			t.Fatalf("seed snapshot: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	completed := testTime.Add(time.Minute)
+	if err := repo.ImportAnalysis(ctx, &models.SnapshotAnalysis{
+		CurrentSnapshotID:  ids[len(ids)-1],
+		PreviousSnapshotID: ids[0],
+		Status:             models.AnalysisStatusCompleted,
+		Result:             "path grew",
+		ToolCalls:          []models.ToolCall{{Name: "get_diff", Args: map[string]any{"limit": float64(5)}}},
+		CreatedAt:          testTime,
+		CompletedAt:        &completed,
+	}); err != nil {
+		t.Fatalf("seed analysis: %v", err)
+	}
+	return ids
+}
+
+// summarize describes an archived snapshot without the IDs, which change on import.
+func summarize(a *models.ArchivedSnapshot) []string {
+	s := a.Snapshot
+	out := []string{fmt.Sprintf("snapshot %s/%s %s %s services=%d series=%d",
+		s.Source, s.Tenant, s.Status, s.CollectedAt.UTC().Format(time.RFC3339), s.TotalServices, s.TotalSeries)}
+	for _, svc := range a.Services {
+		out = append(out, fmt.Sprintf("service %s series=%d metrics=%d", svc.ServiceName, svc.TotalSeries, svc.MetricCount))
+		for _, m := range svc.Metrics {
+			out = append(out, fmt.Sprintf("metric %s series=%d labels=%d", m.MetricName, m.SeriesCount, m.LabelCount))
+			for _, l := range m.Labels {
+				without := "unknown"
+				if l.SeriesWithoutLabel != nil {
+					without = fmt.Sprint(*l.SeriesWithoutLabel)
+				}
+				out = append(out, fmt.Sprintf("label %s unique=%d samples=%v without=%s",
+					l.LabelName, l.UniqueValuesCount, l.SampleValues, without))
+			}
+		}
+	}
+	for _, an := range a.Analyses {
+		out = append(out, fmt.Sprintf("analysis %s %q tools=%d", an.Status, an.Result, len(an.ToolCalls)))
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			ctx := context.Background()
+			src := openTestDB(t)
+			srcIDs := seed(t, src,
+				testSnapshot("prod", testTime, 100),
+				testSnapshot("prod", testTime.Add(time.Hour), 160),
+			)
+
+			var buf bytes.Buffer
+			if err := Export(ctx, storage.NewArchiveRepository(src), srcIDs, format, &buf); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if lines := strings.Count(buf.String(), "\n"); format == FormatNDJSON && lines != 3 {
+				t.Errorf("ndjson archive has %d lines, want a header and one line per snapshot", lines)
+			}
+
+			dst := openTestDB(t)
+			seed(t, dst, testSnapshot("other", testTime, 10)) // imported IDs must not collide
+			dstRepo := storage.NewArchiveRepository(dst)
+			result, err := Import(ctx, dstRepo, &buf)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if len(result.Snapshots) != 2 || result.Analyses != 1 || result.SkippedAnalyses != 0 {
+				t.Fatalf("result = %+v, want 2 snapshots and 1 analysis", result)
+			}
+
+			srcRepo := storage.NewArchiveRepository(src)
+			for i, imported := range result.Snapshots {
+				if imported.ArchiveID != srcIDs[i] {
+					t.Errorf("snapshot %d: archive id %d, want %d", i, imported.ArchiveID, srcIDs[i])
+				}
+				want, err := srcRepo.Export(ctx, imported.ArchiveID)
+				if err != nil {
+					t.Fatalf("export source: %v", err)
+				}
+				got, err := dstRepo.Export(ctx, imported.ID)
+				if err != nil || got == nil {
+					t.Fatalf("export imported snapshot %d: %v", imported.ID, err)
+				}
+				if !slices.Equal(summarize(got), summarize(want)) {
+					t.Errorf("snapshot %d:\ngot  %v\nwant %v", i, summarize(got), summarize(want))
+				}
+			}
+
+			last, err := dstRepo.Export(ctx, result.Snapshots[1].ID)
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if a := last.Analyses[0]; a.CurrentSnapshotID != result.Snapshots[1].ID || a.PreviousSnapshotID != result.Snapshots[0].ID {
+				t.Errorf("analysis compares %d with %d, want the imported ids %d and %d",
+					a.CurrentSnapshotID, a.PreviousSnapshotID, result.Snapshots[1].ID, result.Snapshots[0].ID)
+			}
+		})
+	}
+}
+
+func TestImportSkipsAnalysesOfMissingSnapshots(t *testing.T) {
+	ctx := context.Background()
+	src := openTestDB(t)
+	ids := seed(t, src, testSnapshot("prod", testTime, 100), testSnapshot("prod", testTime.Add(time.Hour), 160))
+
+	var buf bytes.Buffer
+	if err := Export(ctx, storage.NewArchiveRepository(src), ids[1:], FormatJSON, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	result, err := Import(ctx, storage.NewArchiveRepository(openTestDB(t)), &buf)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(result.Snapshots) != 1 || result.Analyses != 0 || result.SkippedAnalyses != 1 {
+		t.Errorf("result = %+v, want 1 snapshot and 1 skipped analysis", result)
+	}
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+	}{
+		{"not json", "scans"},
+		{"missing version", `{"snapshots": []}`},
+		{"future version", `{"version": 99, "snapshots": []}`},
+		{"broken ndjson line", `{"version": 1}` + "\n{"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(context.Background(), storage.NewArchiveRepository(openTestDB(t)), strings.NewReader(tt.archive))
+			if !errors.Is(err, ErrInvalidArchive) {
+				t.Errorf("Import = %v, want ErrInvalidArchive", err)
+			}
+		})
+	}
+}
+
+// cancellingRepo cancels the import after its first snapshot, as a client that goes away would.
+type cancellingRepo struct {
+	*storage.ArchiveRepository
+	cancel context.CancelFunc
+}
+
+func (r *cancellingRepo) Import(ctx context.Context, a *models.ArchivedSnapshot) (int64, error) {
+	id, err := r.ArchiveRepository.Import(ctx, a)
+	r.cancel()
+	return id, err
+}
+
+func TestImportCancelledDeletesImportedSnapshots(t *testing.T) {
+	src := openTestDB(t)
+	ids := seed(t, src, testSnapshot("prod", testTime, 100), testSnapshot("prod", testTime.Add(time.Hour), 160))
+	var buf bytes.Buffer
+	if err := Export(context.Background(), storage.NewArchiveRepository(src), ids, FormatNDJSON, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dst := openTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result, err := Import(ctx, &cancellingRepo{storage.NewArchiveRepository(dst), cancel}, &buf)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Import = %v, want context.Canceled", err)
+	}
+	if len(result.Snapshots) != 0 {
+		t.Errorf("result lists %d snapshots, want none left", len(result.Snapshots))
+	}
+
+	snapshots, err := storage.NewSnapshotsRepository(dst).List(context.Background(), storage.SnapshotFilter{}, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("%d snapshots left after the cancelled import", len(snapshots))
+	}
+}
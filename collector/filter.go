@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/illenko/whodidthis/config"
+)
+
+// ErrNoMatchingServices is returned by a targeted scan when no source has a service matching its filter.
+var ErrNoMatchingServices = errors.New("no services match the scan filter")
+
+// ServiceFilter selects the services of a targeted scan: those listed in Names or matching Regex.
+type ServiceFilter struct {
+	Names []string
+	Regex *regexp.Regexp
+}
+
+func (f *ServiceFilter) Match(name string) bool {
+	if slices.Contains(f.Names, name) {
+		return true
+	}
+	return f.Regex != nil && f.Regex.MatchString(name)
+}
+
+// String describes the filter as recorded on targeted snapshots, e.g. "api,worker,/^payments-/".
+func (f *ServiceFilter) String() string {
+	parts := slices.Clone(f.Names)
+	if f.Regex != nil {
+		parts = append(parts, "/"+f.Regex.String()+"/")
+	}
+	return strings.Join(parts, ",")
+}
+
+// nameFilter applies a configured include/exclude PatternList.
+type nameFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// newNameFilter compiles the patterns, which config.Validate has already checked.
+func newNameFilter(p config.PatternList) *nameFilter {
+	compile := func(patterns []string) []*regexp.Regexp {
+		res := make([]*regexp.Regexp, 0, len(patterns))
+		for _, pattern := range patterns {
+			res = append(res, regexp.MustCompile(config.AnchoredPattern(pattern)))
+		}
+		return res
+	}
+	return &nameFilter{
+		include: compile(p.Include),
+		exclude: compile(p.Exclude),
+	}
+}
+
+func (f *nameFilter) allows(name string) bool {
+	matches := func(re *regexp.Regexp) bool { return re.MatchString(name) }
+	if len(f.include) > 0 && !slices.ContainsFunc(f.include, matches) {
+		return false
+	}
+	return !slices.ContainsFunc(f.exclude, matches)
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/illenko/whodidthis/config"
+)
+
+func TestServiceFilter(t *testing.T) {
+	f := &ServiceFilter{Names: []string{"api", "worker"}, Regex: regexp.MustCompile("^payments-")}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"api", true},
+		{"worker", true},
+		{"payments-eu", true},
+		{"api-gateway", false},
+		{"legacy-payments-eu", false},
+	}
+	for _, tt := range tests {
+		if got := f.Match(tt.name); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got, want := f.String(), "api,worker,/^payments-/"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := (&ServiceFilter{Names: []string{"api"}}).String(); got != "api" {
+		t.Errorf("String() without regex = %q, want api", got)
+	}
+}
+
+func TestNameFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns config.PatternList
+		allowed  []string
+		denied   []string
+	}{
+		{
+			name:    "empty allows everything",
+			allowed: []string{"up", "http_requests_total"},
+		},
+		{
+			name:     "exclude",
+			patterns: config.PatternList{Exclude: []string{"up", "scrape_.*", "go_.*"}},
+			allowed:  []string{"http_requests_total", "upstream_latency", "my_go_thing"},
+			denied:   []string{"up", "scrape_duration_seconds", "go_goroutines"},
+		},
+		{
+			name:     "include",
+			patterns: config.PatternList{Include: []string{"http_.*", "grpc_.*"}},
+			allowed:  []string{"http_requests_total", "grpc_server_handled_total"},
+			denied:   []string{"up", "xhttp_requests_total"},
+		},
+		{
+			name:     "exclude wins over include",
+			patterns: config.PatternList{Include: []string{"http_.*"}, Exclude: []string{".*_bucket"}},
+			allowed:  []string{"http_requests_total"},
+			denied:   []string{"http_request_duration_seconds_bucket", "up"},
+		},
+		{
+			name:     "alternation is anchored as a whole",
+			patterns: config.PatternList{Exclude: []string{"up|down"}},
+			allowed:  []string{"upstream", "slowdown"},
+			denied:   []string{"up", "down"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newNameFilter(tt.patterns)
+			for _, name := range tt.allowed {
+				if !f.allows(name) {
+					t.Errorf("allows(%q) = false, want true", name)
+				}
+			}
+			for _, name := range tt.denied {
+				if f.allows(name) {
+					t.Errorf("allows(%q) = true, want false", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package collector
+
+import "github.com/illenko/whodidthis/config"
+
+// Profile holds the settings that vary between scan profiles, e.g. a light hourly
+// tsdb scan and a deep nightly full scan.
+type Profile struct {
+	Name        string
+	Mode        string
+	SampleLimit int
+	services    *nameFilter
+	metrics     *nameFilter
+}
+
+// NewProfile builds the profile; nil filters scan every service and metric.
+func NewProfile(p config.ScanProfile) *Profile {
+	var filters config.FiltersConfig
+	if p.Filters != nil {
+		filters = *p.Filters
+	}
+	return &Profile{
+		Name:        p.Name,
+		Mode:        p.Mode,
+		SampleLimit: p.SampleValuesLimit,
+		services:    newNameFilter(filters.Services),
+		metrics:     newNameFilter(filters.Metrics),
+	}
+}
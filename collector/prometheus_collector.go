@@ -2,8 +2,10 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,55 +14,146 @@ import (
 	"github.com/illenko/whodidthis/models"
 	"github.com/illenko/whodidthis/prometheus"
 	"github.com/illenko/whodidthis/storage"
+	"github.com/illenko/whodidthis/telemetry"
 )
 
 const perServiceTimeout = 2 * time.Minute
 
+// Source is a Prometheus endpoint to scan. Each source and tenant pair gets its own snapshot.
+type Source struct {
+	Name         string
+	Client       prometheus.MetricsClient
+	ServiceLabel string
+	Tenants      []string
+}
+
 type Collector struct {
-	client       prometheus.MetricsClient
-	snapshots    storage.SnapshotsRepo
-	services     storage.ServicesRepo
-	metrics      storage.MetricsRepo
-	labels       storage.LabelsRepo
-	serviceLabel string
-	sampleLimit  int
-	concurrency  int
-	logger       *slog.Logger
+	sources               []Source
+	snapshots             storage.SnapshotsRepo
+	services              storage.ServicesRepo
+	metrics               storage.MetricsRepo
+	labels                storage.LabelsRepo
+	tsdbStats             storage.TSDBStatsRepo
+	runs                  storage.ScanRunsRepo
+	defaultProfile        *Profile
+	tsdbStatusLimit       int
+	concurrency           int
+	dropEstimateMinSeries int
+	maxSeriesPerScan      int64
+	logger                *slog.Logger
 }
 
 func NewCollector(
-	client prometheus.MetricsClient,
+	sources []Source,
 	snapshots storage.SnapshotsRepo,
 	services storage.ServicesRepo,
 	metrics storage.MetricsRepo,
 	labels storage.LabelsRepo,
+	tsdbStats storage.TSDBStatsRepo,
+	runs storage.ScanRunsRepo,
 	cfg *config.Config,
 ) *Collector {
 	return &Collector{
-		client:       client,
-		snapshots:    snapshots,
-		services:     services,
-		metrics:      metrics,
-		labels:       labels,
-		serviceLabel: cfg.Discovery.ServiceLabel,
-		sampleLimit:  cfg.Scan.SampleValuesLimit,
-		concurrency:  cfg.Scan.Concurrency,
-		logger:       slog.Default(),
+		sources:               sources,
+		snapshots:             snapshots,
+		services:              services,
+		metrics:               metrics,
+		labels:                labels,
+		tsdbStats:             tsdbStats,
+		runs:                  runs,
+		defaultProfile:        defaultProfile(cfg),
+		tsdbStatusLimit:       cfg.Scan.TSDBStatusLimit,
+		concurrency:           cfg.Scan.Concurrency,
+		dropEstimateMinSeries: cfg.Scan.DropEstimateMinSeries,
+		maxSeriesPerScan:      cfg.Prometheus.Budget.MaxSeriesPerScan,
+		logger:                slog.Default(),
+	}
+}
+
+// defaultProfile is the first configured profile, which runs when no profile is given.
+func defaultProfile(cfg *config.Config) *Profile {
+	if len(cfg.Scan.Profiles) > 0 {
+		return NewProfile(cfg.Scan.Profiles[0])
 	}
+	return NewProfile(config.ScanProfile{
+		Name:              "default",
+		Mode:              cfg.Scan.Mode,
+		SampleValuesLimit: cfg.Scan.SampleValuesLimit,
+		Filters:           &cfg.Scan.Filters,
+	})
 }
 
 type CollectResult struct {
-	SnapshotID    int64
+	SnapshotIDs   []int64 // one per successfully scanned source and tenant
 	TotalServices int
 	TotalSeries   int64
 	Duration      time.Duration
 	ServiceErrors int
+	MetricErrors  int // metrics stored without label details
+	TargetErrors  int
+	Err           error // why the failed targets failed, nil when TargetErrors is 0
+}
+
+// Partial reports whether anything was left out of the scan's snapshots.
+func (r *CollectResult) Partial() bool {
+	return r.TargetErrors > 0 || r.ServiceErrors > 0 || r.MetricErrors > 0
+}
+
+// target is one source and tenant pair of a scan. An empty tenant scans without X-Scope-OrgID.
+type target struct {
+	source   Source
+	tenant   string
+	profile  *Profile
+	failures *failureLog
+}
+
+// failureLog collects the service and metric failures of a target while its services are scanned concurrently.
+type failureLog struct {
+	mu       sync.Mutex
+	services int
+	metrics  int
+	items    []*models.ScanFailure
+}
+
+func (l *failureLog) add(serviceName, metricName string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if metricName == "" {
+		l.services++
+	} else {
+		l.metrics++
+	}
+	l.items = append(l.items, &models.ScanFailure{
+		ServiceName: serviceName,
+		MetricName:  metricName,
+		Reason:      err.Error(),
+	})
 }
 
 type ProgressCallback func(phase string, current, total int, detail string)
 
-func (c *Collector) Collect(ctx context.Context, scanID int64, progress ProgressCallback) (*CollectResult, error) {
-	logger := c.logger.With("scan_id", scanID)
+// Collect scans every source and tenant. scanID is the scan run the snapshots and failures are recorded under.
+// A nil profile runs the default profile. A non-nil filter makes it a targeted scan whose
+// snapshots hold only the matching services.
+func (c *Collector) Collect(ctx context.Context, scanID int64, profile *Profile, filter *ServiceFilter, progress ProgressCallback) (*CollectResult, error) {
+	start := time.Now()
+
+	if profile == nil {
+		profile = c.defaultProfile
+	}
+	result, err := c.collect(ctx, scanID, profile, filter, progress)
+
+	telemetry.ScanDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		telemetry.ScansTotal.WithLabelValues("failed").Inc()
+	} else {
+		telemetry.ScansTotal.WithLabelValues("success").Inc()
+	}
+
+	return result, err
+}
+
+func (c *Collector) collect(ctx context.Context, scanID int64, profile *Profile, filter *ServiceFilter, progress ProgressCallback) (*CollectResult, error) {
 	start := time.Now()
 	collectedAt := start.Truncate(time.Second)
 
@@ -68,27 +161,167 @@ func (c *Collector) Collect(ctx context.Context, scanID int64, progress Progress
 		progress = func(string, int, int, string) {}
 	}
 
-	logger.Info("starting service discovery", "label", c.serviceLabel)
-	progress("discovering", 0, 0, "Discovering services...")
+	var targets []target
+	for _, src := range c.sources {
+		if len(src.Tenants) == 0 {
+			targets = append(targets, target{source: src, profile: profile})
+			continue
+		}
+		for _, tenant := range src.Tenants {
+			targets = append(targets, target{source: src, tenant: tenant, profile: profile})
+		}
+	}
+
+	var budget *prometheus.SeriesBudget
+	if c.maxSeriesPerScan > 0 {
+		budget = prometheus.NewSeriesBudget(c.maxSeriesPerScan)
+		ctx = prometheus.WithSeriesBudget(ctx, budget)
+	}
+
+	result := &CollectResult{}
+	var errs []error
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		t.failures = &failureLog{}
+		snapshot, err := c.collectTarget(ctx, scanID, t, filter, collectedAt, progress)
+		if err != nil && ctx.Err() != nil {
+			errs = append(errs, err)
+			break
+		}
+		if errors.Is(err, ErrNoMatchingServices) {
+			// Targeted scans name services that usually live in just one source or tenant.
+			c.logger.Debug("no matching services in target", "scan_id", scanID, "source", t.source.Name, "tenant", t.tenant)
+			continue
+		}
+		if err != nil {
+			if len(targets) > 1 {
+				scope := models.Snapshot{Source: t.source.Name, Tenant: t.tenant}
+				err = fmt.Errorf("%s: %w", scope.Scope(), err)
+			}
+			c.logger.Error("target collection failed", "scan_id", scanID, "source", t.source.Name, "tenant", t.tenant, "error", err)
+			errs = append(errs, err)
+			result.TargetErrors++
+			continue
+		}
+
+		result.SnapshotIDs = append(result.SnapshotIDs, snapshot.ID)
+		result.TotalServices += snapshot.TotalServices
+		result.TotalSeries += snapshot.TotalSeries
+		result.ServiceErrors += t.failures.services
+		result.MetricErrors += t.failures.metrics
+	}
+	result.Duration = time.Since(start)
+	if result.TargetErrors > 0 {
+		result.Err = errors.Join(errs...)
+	}
+
+	if budget != nil && budget.Exhausted() {
+		c.logger.Warn("series budget exhausted: later metrics were stored without label details",
+			"scan_id", scanID, "max_series_per_scan", c.maxSeriesPerScan)
+	}
+
+	if len(result.SnapshotIDs) == 0 {
+		if len(errs) == 0 && filter != nil {
+			return nil, ErrNoMatchingServices
+		}
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// collectTarget scans every service visible to the target into a new snapshot.
+func (c *Collector) collectTarget(ctx context.Context, scanID int64, t target, filter *ServiceFilter, collectedAt time.Time, progress ProgressCallback) (*models.Snapshot, error) {
+	logger := c.logger.With("scan_id", scanID)
+	if t.source.Name != "" {
+		logger = logger.With("source", t.source.Name)
+	}
+	if t.tenant != "" {
+		logger = logger.With("tenant", t.tenant)
+		ctx = prometheus.WithTenant(ctx, t.tenant)
+	}
+	start := time.Now()
 
 	snapshot := &models.Snapshot{
+		ScanRunID:   scanID,
+		Source:      t.source.Name,
+		Tenant:      t.tenant,
+		Status:      models.ScanRunStatusRunning,
+		Profile:     t.profile.Name,
 		CollectedAt: collectedAt,
 	}
+	if filter != nil {
+		snapshot.ServiceFilter = filter.String()
+	}
+
+	detail := func(s string) string {
+		if scope := snapshot.Scope(); scope != "" {
+			return scope + ": " + s
+		}
+		return s
+	}
+
+	logger.Info("starting service discovery", "label", t.source.ServiceLabel)
+	progress("discovering", 0, 0, detail("Discovering services..."))
+
+	writeStart := time.Now()
 	snapshotID, err := c.snapshots.Create(ctx, snapshot)
+	observeWrite("snapshot_create", writeStart)
 	if err != nil {
 		return nil, err
 	}
 	snapshot.ID = snapshotID
 
-	serviceInfos, err := c.client.DiscoverServices(ctx, c.serviceLabel)
+	// Bookkeeping writes must land even after the scan was aborted or cancelled.
+	writeCtx := context.WithoutCancel(ctx)
+
+	// An incomplete snapshot would read as a cardinality drop in the history, so a target
+	// that fails is discarded.
+	discard := func(cause error) (*models.Snapshot, error) {
+		if err := c.snapshots.Delete(writeCtx, snapshotID); err != nil {
+			logger.Error("failed to delete incomplete snapshot", "snapshot_id", snapshotID, "error", err)
+		}
+		return nil, cause
+	}
+
+	// The scan of this target stops as soon as the source's circuit breaker opens.
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	if t.profile.Mode == config.ScanModeTSDB {
+		c.collectTSDBStatus(ctx, t, snapshotID, logger)
+	}
+
+	serviceInfos, err := t.source.Client.DiscoverServices(ctx, t.source.ServiceLabel)
 	if err != nil {
-		return nil, err
+		return discard(err)
+	}
+
+	discovered := len(serviceInfos)
+	serviceInfos = slices.DeleteFunc(serviceInfos, func(svc prometheus.ServiceInfo) bool {
+		return !t.profile.services.allows(svc.Name)
+	})
+	if skipped := discovered - len(serviceInfos); skipped > 0 {
+		logger.Debug("services excluded by scan filters", "count", skipped)
+	}
+
+	if filter != nil {
+		serviceInfos = slices.DeleteFunc(serviceInfos, func(svc prometheus.ServiceInfo) bool {
+			return !filter.Match(svc.Name)
+		})
+		if len(serviceInfos) == 0 {
+			return discard(ErrNoMatchingServices)
+		}
+		logger.Info("targeted scan", "filter", snapshot.ServiceFilter)
 	}
 
 	logger.Info("discovered services", "count", len(serviceInfos))
 
 	var totalSeries atomic.Int64
-	var serviceErrors atomic.Int64
+	var storedServices atomic.Int64
 
 	sem := make(chan struct{}, c.concurrency)
 	var wg sync.WaitGroup
@@ -118,64 +351,109 @@ func (c *Collector) Collect(ctx context.Context, scanID int64, progress Progress
 			logger.Debug("scanning service", "name", svc.Name)
 
 			mu.Lock()
-			progress("processing_service", completed, len(serviceInfos), svc.Name)
+			progress("processing_service", completed, len(serviceInfos), detail(svc.Name))
 			mu.Unlock()
 
-			serviceSnapshot, err := c.collectService(svcCtx, snapshotID, svc, sem)
+			serviceSnapshot, err := c.collectService(svcCtx, t, snapshotID, svc, sem)
 
 			mu.Lock()
 			completed++
-			progress("service_complete", completed, len(serviceInfos), svc.Name)
+			progress("service_complete", completed, len(serviceInfos), detail(svc.Name))
 			mu.Unlock()
 
 			if err != nil {
-				serviceErrors.Add(1)
+				telemetry.ServicesScannedTotal.WithLabelValues("failed").Inc()
+				if errors.Is(err, prometheus.ErrCircuitOpen) {
+					abort(err)
+					return
+				}
+				if ctx.Err() != nil {
+					return // cancelled, not failed
+				}
+				t.failures.add(svc.Name, "", err)
 				logger.Error("failed to collect service", "name", svc.Name, "error", err)
 				return
 			}
+			telemetry.ServicesScannedTotal.WithLabelValues("success").Inc()
 
 			totalSeries.Add(int64(serviceSnapshot.TotalSeries))
+			storedServices.Add(1)
 		}(svc)
 	}
 
 	wg.Wait()
 
-	finalTotalSeries := totalSeries.Load()
+	if cause := context.Cause(ctx); errors.Is(cause, prometheus.ErrCircuitOpen) {
+		logger.Warn("aborting scan: prometheus circuit breaker open", "snapshot_id", snapshotID)
+		return discard(cause)
+	}
+
 	snapshot.TotalServices = len(serviceInfos)
-	snapshot.TotalSeries = finalTotalSeries
+	snapshot.TotalSeries = totalSeries.Load()
 	snapshot.ScanDurationMs = int(time.Since(start).Milliseconds())
+	snapshot.Status = models.ScanRunStatusCompleted
+	if len(t.failures.items) > 0 {
+		snapshot.Status = models.ScanRunStatusPartial
+	}
+	// A cancelled snapshot keeps the services that finished; unfinished ones were removed by collectService.
+	cancelled := ctx.Err() != nil
+	if cancelled {
+		snapshot.Status = models.ScanRunStatusCancelled
+		snapshot.TotalServices = int(storedServices.Load())
+	}
+
+	for _, f := range t.failures.items {
+		f.ScanRunID = scanID
+		f.SnapshotID = snapshotID
+	}
+	writeStart = time.Now()
+	err = c.runs.AddFailures(writeCtx, t.failures.items)
+	observeWrite("scan_failures_create", writeStart)
+	if err != nil {
+		logger.Error("failed to store scan failures", "snapshot_id", snapshotID, "error", err)
+	}
 
-	if err := c.snapshots.Update(ctx, snapshot); err != nil {
+	writeStart = time.Now()
+	err = c.snapshots.Update(writeCtx, snapshot)
+	observeWrite("snapshot_update", writeStart)
+	if err != nil {
 		return nil, err
 	}
 
-	duration := time.Since(start)
-	svcErrors := int(serviceErrors.Load())
+	if cancelled {
+		logger.Warn("collection cancelled",
+			"snapshot_id", snapshotID,
+			"services", snapshot.TotalServices,
+			"total_series", snapshot.TotalSeries,
+			"duration", time.Since(start),
+		)
+		return nil, context.Cause(ctx)
+	}
 
 	logger.Info("collection complete",
-		"services", len(serviceInfos),
-		"total_series", finalTotalSeries,
-		"service_errors", svcErrors,
-		"duration", duration,
+		"snapshot_id", snapshotID,
+		"services", snapshot.TotalServices,
+		"total_series", snapshot.TotalSeries,
+		"service_errors", t.failures.services,
+		"metric_errors", t.failures.metrics,
+		"duration", time.Since(start),
 	)
 
-	return &CollectResult{
-		SnapshotID:    snapshotID,
-		TotalServices: len(serviceInfos),
-		TotalSeries:   finalTotalSeries,
-		Duration:      duration,
-		ServiceErrors: svcErrors,
-	}, nil
+	return snapshot, nil
 }
 
-func (c *Collector) collectService(ctx context.Context, snapshotID int64, svc prometheus.ServiceInfo, sem chan struct{}) (*models.ServiceSnapshot, error) {
-	metricInfos, err := c.client.GetMetricsForService(ctx, c.serviceLabel, svc.Name)
+func (c *Collector) collectService(ctx context.Context, t target, snapshotID int64, svc prometheus.ServiceInfo, sem chan struct{}) (*models.ServiceSnapshot, error) {
+	metricInfos, err := t.source.Client.GetMetricsForService(ctx, t.source.ServiceLabel, svc.Name)
 	// Release the service-level sem slot so metric goroutines can use the pool.
 	<-sem
 	if err != nil {
 		return nil, fmt.Errorf("get metrics for %s: %w", svc.Name, err)
 	}
 
+	metricInfos = slices.DeleteFunc(metricInfos, func(metric prometheus.MetricInfo) bool {
+		return !t.profile.metrics.allows(metric.Name)
+	})
+
 	c.logger.Debug("found metrics for service",
 		"service", svc.Name,
 		"metrics", len(metricInfos),
@@ -189,13 +467,16 @@ func (c *Collector) collectService(ctx context.Context, snapshotID int64, svc pr
 		MetricCount: len(metricInfos),
 	}
 
+	writeStart := time.Now()
 	serviceSnapshotID, err := c.services.Create(ctx, serviceSnapshot)
+	observeWrite("service_create", writeStart)
 	if err != nil {
 		return nil, fmt.Errorf("create service snapshot %s: %w", svc.Name, err)
 	}
 	serviceSnapshot.ID = serviceSnapshotID
 
 	var metricWg sync.WaitGroup
+	var circuitOpen atomic.Bool
 	for _, metric := range metricInfos {
 		if ctx.Err() != nil {
 			break
@@ -218,7 +499,10 @@ func (c *Collector) collectService(ctx context.Context, snapshotID int64, svc pr
 				"series", metric.SeriesCount,
 			)
 
-			if err := c.collectMetric(ctx, serviceSnapshotID, svc.Name, metric); err != nil {
+			if err := c.collectMetric(ctx, t, serviceSnapshotID, svc.Name, metric); err != nil {
+				if errors.Is(err, prometheus.ErrCircuitOpen) {
+					circuitOpen.Store(true)
+				}
 				c.logger.Debug("failed to collect metric", "service", svc.Name, "metric", metric.Name, "error", err)
 			}
 		}(metric)
@@ -226,19 +510,44 @@ func (c *Collector) collectService(ctx context.Context, snapshotID int64, svc pr
 
 	metricWg.Wait()
 
+	if circuitOpen.Load() {
+		return nil, fmt.Errorf("collect metrics for %s: %w", svc.Name, prometheus.ErrCircuitOpen)
+	}
+	if err := ctx.Err(); err != nil {
+		// Drop the half-written service rather than keep it with some of its metrics missing.
+		if delErr := c.services.Delete(context.WithoutCancel(ctx), serviceSnapshotID); delErr != nil {
+			c.logger.Error("failed to delete incomplete service snapshot", "service", svc.Name, "error", delErr)
+		}
+		return nil, fmt.Errorf("collect metrics for %s: %w", svc.Name, err)
+	}
+
 	return serviceSnapshot, nil
 }
 
-func (c *Collector) collectMetric(ctx context.Context, serviceSnapshotID int64, serviceName string, metric prometheus.MetricInfo) error {
-	labelInfos, err := c.client.GetLabelsForMetric(ctx, c.serviceLabel, serviceName, metric.Name, c.sampleLimit)
-	if err != nil {
-		c.logger.Debug("failed to get labels", "metric", metric.Name, "error", err)
-		labelInfos = nil
-	} else {
-		c.logger.Debug("collected labels",
-			"metric", metric.Name,
-			"labels", len(labelInfos),
-		)
+func (c *Collector) collectMetric(ctx context.Context, t target, serviceSnapshotID int64, serviceName string, metric prometheus.MetricInfo) error {
+	// tsdb mode skips the per-metric /series call; label details come from the TSDB status overview.
+	var labelInfos []prometheus.LabelInfo
+	if t.profile.Mode != config.ScanModeTSDB {
+		var err error
+		labelInfos, err = t.source.Client.GetLabelsForMetric(ctx, t.source.ServiceLabel, serviceName, metric, t.profile.SampleLimit)
+		if errors.Is(err, prometheus.ErrCircuitOpen) {
+			return err
+		}
+		if errors.Is(err, prometheus.ErrSeriesBudgetExhausted) {
+			// The operator capped the scan; the metric keeps its series count and is not a failure.
+			labelInfos = nil
+		} else if err != nil {
+			c.logger.Debug("failed to get labels", "metric", metric.Name, "error", err)
+			if ctx.Err() == nil {
+				t.failures.add(serviceName, metric.Name, err)
+			}
+			labelInfos = nil
+		} else {
+			c.logger.Debug("collected labels",
+				"metric", metric.Name,
+				"labels", len(labelInfos),
+			)
+		}
 	}
 
 	metricSnapshot := &models.MetricSnapshot{
@@ -248,7 +557,9 @@ func (c *Collector) collectMetric(ctx context.Context, serviceSnapshotID int64,
 		LabelCount:        len(labelInfos),
 	}
 
+	writeStart := time.Now()
 	metricSnapshotID, err := c.metrics.Create(ctx, metricSnapshot)
+	observeWrite("metric_create", writeStart)
 	if err != nil {
 		return err
 	}
@@ -264,10 +575,75 @@ func (c *Collector) collectMetric(ctx context.Context, serviceSnapshotID int64,
 			})
 		}
 
-		if err := c.labels.CreateBatch(ctx, labelSnapshots); err != nil {
+		if c.dropEstimateMinSeries > 0 && metric.SeriesCount >= c.dropEstimateMinSeries {
+			c.estimateLabelDrops(ctx, t, serviceName, metric.Name, labelSnapshots)
+		}
+
+		writeStart = time.Now()
+		err = c.labels.CreateBatch(ctx, labelSnapshots)
+		observeWrite("labels_create_batch", writeStart)
+		if err != nil {
 			c.logger.Debug("failed to batch store labels", "metric", metric.Name, "error", err)
 		}
 	}
 
 	return nil
 }
+
+// collectTSDBStatus stores the TSDB status overview of the target with the snapshot.
+// Failures are logged; the snapshot still gets its services and metrics.
+func (c *Collector) collectTSDBStatus(ctx context.Context, t target, snapshotID int64, logger *slog.Logger) {
+	status, err := t.source.Client.GetTSDBStatus(ctx, c.tsdbStatusLimit)
+	if err != nil {
+		logger.Warn("failed to get tsdb status", "error", err)
+		return
+	}
+
+	overview := &models.TSDBOverview{
+		SnapshotID:                  snapshotID,
+		HeadSeries:                  status.HeadSeries,
+		SeriesCountByMetricName:     convertStats(status.SeriesCountByMetricName),
+		LabelValueCountByLabelName:  convertStats(status.LabelValueCountByLabelName),
+		SeriesCountByLabelValuePair: convertStats(status.SeriesCountByLabelValuePair),
+		MemoryInBytesByLabelName:    convertStats(status.MemoryInBytesByLabelName),
+	}
+
+	writeStart := time.Now()
+	err = c.tsdbStats.Create(ctx, overview)
+	observeWrite("tsdb_stats_create", writeStart)
+	if err != nil {
+		logger.Warn("failed to store tsdb status", "error", err)
+		return
+	}
+
+	logger.Info("collected tsdb status", "head_series", status.HeadSeries)
+}
+
+func convertStats(stats []prometheus.Stat) []models.TSDBStat {
+	out := make([]models.TSDBStat, 0, len(stats))
+	for _, s := range stats {
+		out = append(out, models.TSDBStat{Name: s.Name, Value: s.Value})
+	}
+	return out
+}
+
+// estimateLabelDrops records how many series the metric would keep if each label were dropped.
+// Failures are logged and leave the estimate empty.
+func (c *Collector) estimateLabelDrops(ctx context.Context, t target, serviceName, metricName string, labels []*models.LabelSnapshot) {
+	for _, label := range labels {
+		if ctx.Err() != nil {
+			return
+		}
+
+		count, err := t.source.Client.GetSeriesCountWithoutLabel(ctx, t.source.ServiceLabel, serviceName, metricName, label.LabelName)
+		if err != nil {
+			c.logger.Debug("failed to estimate label drop", "metric", metricName, "label", label.LabelName, "error", err)
+			continue
+		}
+		label.SeriesWithoutLabel = &count
+	}
+}
+
+func observeWrite(operation string, start time.Time) {
+	telemetry.StorageWriteDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
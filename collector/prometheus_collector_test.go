@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/prometheus"
+	"github.com/illenko/whodidthis/storage"
+)
+
+// fakeClient serves services and metrics from memory. Label lookups return labelErrs[metric]
+// when set.
+type fakeClient struct {
+	prometheus.MetricsClient
+	metrics   map[string][]prometheus.MetricInfo // by service
+	labelErrs map[string]error
+}
+
+func (c *fakeClient) DiscoverServices(context.Context, string) ([]prometheus.ServiceInfo, error) {
+	var services []prometheus.ServiceInfo
+	for name, metrics := range c.metrics {
+		svc := prometheus.ServiceInfo{Name: name}
+		for _, m := range metrics {
+			svc.SeriesCount += m.SeriesCount
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+func (c *fakeClient) GetMetricsForService(_ context.Context, _, serviceName string) ([]prometheus.MetricInfo, error) {
+	return c.metrics[serviceName], nil
+}
+
+func (c *fakeClient) GetLabelsForMetric(_ context.Context, _, _ string, metric prometheus.MetricInfo, _ int) ([]prometheus.LabelInfo, error) {
+	if err := c.labelErrs[metric.Name]; err != nil {
+		return nil, err
+	}
+	return []prometheus.LabelInfo{{Name: "path", UniqueValues: metric.SeriesCount, SampleValues: []string{"/"}}}, nil
+}
+
+type testEnv struct {
+	db        *storage.DB
+	collector *Collector
+	snapshots *storage.SnapshotsRepository
+	runs      *storage.ScanRunsRepository
+}
+
+func newTestEnv(t *testing.T, client prometheus.MetricsClient) *testEnv {
+	t.Helper()
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{}
+	cfg.Scan.Mode = config.ScanModeFull
+	cfg.Scan.SampleValuesLimit = 5
+	cfg.Scan.Concurrency = 4
+
+	env := &testEnv{
+		db:        db,
+		snapshots: storage.NewSnapshotsRepository(db),
+		runs:      storage.NewScanRunsRepository(db),
+	}
+	env.collector = NewCollector(
+		[]Source{{Client: client, ServiceLabel: "job"}},
+		env.snapshots,
+		storage.NewServicesRepository(db),
+		storage.NewMetricsRepository(db),
+		storage.NewLabelsRepository(db),
+		storage.NewTSDBStatsRepository(db),
+		env.runs,
+		cfg,
+	)
+	return env
+}
+
+func (e *testEnv) newRun(t *testing.T) int64 {
+	t.Helper()
+	run, err := e.runs.Create(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("create scan run: %v", err)
+	}
+	return run.ID
+}
+
+func TestCollectSeriesBudgetExhausted(t *testing.T) {
+	exhausted := fmt.Errorf("get labels: %w", prometheus.ErrSeriesBudgetExhausted)
+	env := newTestEnv(t, &fakeClient{
+		metrics: map[string][]prometheus.MetricInfo{
+			"api": {{Name: "http_requests_total", SeriesCount: 50}, {Name: "http_duration_bucket", SeriesCount: 500}, {Name: "up", SeriesCount: 1}},
+		},
+		labelErrs: map[string]error{"http_duration_bucket": exhausted, "up": exhausted},
+	})
+	ctx := context.Background()
+	scanID := env.newRun(t)
+
+	result, err := env.collector.Collect(ctx, scanID, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if result.Partial() || result.MetricErrors != 0 {
+		t.Errorf("result = %+v, want a complete scan", result)
+	}
+
+	snapshot, err := env.snapshots.GetByID(ctx, result.SnapshotIDs[0])
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if snapshot.Status != models.ScanRunStatusCompleted {
+		t.Errorf("snapshot status = %q, want completed", snapshot.Status)
+	}
+	failures, err := env.runs.ListFailures(ctx, scanID, 0)
+	if err != nil {
+		t.Fatalf("ListFailures: %v", err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("failures = %+v, want none for metrics over the budget", failures)
+	}
+
+	svc, err := storage.NewServicesRepository(env.db).GetByName(ctx, snapshot.ID, "api")
+	if err != nil || svc == nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	metrics := storage.NewMetricsRepository(env.db)
+	for name, wantLabels := range map[string]int{"http_requests_total": 1, "http_duration_bucket": 0, "up": 0} {
+		m, err := metrics.GetByName(ctx, svc.ID, name)
+		if err != nil || m == nil {
+			t.Fatalf("GetByName %s: %v", name, err)
+		}
+		if m.LabelCount != wantLabels {
+			t.Errorf("%s has %d labels, want %d", name, m.LabelCount, wantLabels)
+		}
+	}
+}
+
+// blockingClient blocks label lookups of the block metric until the scan is cancelled.
+type blockingClient struct {
+	*fakeClient
+	block   string
+	started chan struct{}
+}
+
+func (c *blockingClient) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName string, metric prometheus.MetricInfo, sampleLimit int) ([]prometheus.LabelInfo, error) {
+	if metric.Name == c.block {
+		close(c.started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return c.fakeClient.GetLabelsForMetric(ctx, serviceLabel, serviceName, metric, sampleLimit)
+}
+
+func TestCollectCancelled(t *testing.T) {
+	client := &blockingClient{fakeClient: &fakeClient{metrics: map[string][]prometheus.MetricInfo{
+		"api":    {{Name: "http_requests_total", SeriesCount: 50}},
+		"worker": {{Name: "jobs_total", SeriesCount: 10}, {Name: "queue_depth", SeriesCount: 5}},
+	}}}
+	env := newTestEnv(t, client)
+	ctx := context.Background()
+
+	full, err := env.collector.Collect(ctx, env.newRun(t), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("full scan: %v", err)
+	}
+
+	// Cancel once api is stored and worker is stuck on a label lookup.
+	client.block, client.started = "queue_depth", make(chan struct{})
+	apiDone := make(chan struct{})
+	progress := func(phase string, _, _ int, detail string) {
+		if phase == "service_complete" && detail == "api" {
+			close(apiDone)
+		}
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		<-client.started
+		<-apiDone
+		cancel()
+	}()
+	if _, err := env.collector.Collect(cancelCtx, env.newRun(t), nil, nil, progress); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled scan: err = %v, want context.Canceled", err)
+	}
+	client.block = ""
+
+	snapshots, err := env.snapshots.List(ctx, storage.SnapshotFilter{}, 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID == full.SnapshotIDs[0] {
+		t.Fatalf("snapshots = %+v, want the full and the cancelled one", snapshots)
+	}
+	cancelled := snapshots[0]
+	if cancelled.Status != models.ScanRunStatusCancelled || cancelled.TotalServices != 1 {
+		t.Errorf("cancelled snapshot = %+v, want status cancelled with 1 service", cancelled)
+	}
+	services, err := storage.NewServicesRepository(env.db).List(ctx, cancelled.ID, storage.ServiceListOptions{})
+	if err != nil {
+		t.Fatalf("List services: %v", err)
+	}
+	if len(services) != 1 || services[0].ServiceName != "api" {
+		t.Errorf("cancelled snapshot keeps %+v, want only the finished api service", services)
+	}
+
+	targeted, err := env.collector.Collect(ctx, env.newRun(t), nil, &ServiceFilter{Names: []string{"api"}}, nil)
+	if err != nil {
+		t.Fatalf("targeted scan: %v", err)
+	}
+
+	// Neither the cancelled nor the targeted snapshot replaces the full one as the latest.
+	latest, err := env.snapshots.GetLatest(ctx, storage.SnapshotFilter{})
+	if err != nil {
+		t.Fatalf("GetLatest: %v", err)
+	}
+	if latest == nil || latest.ID != full.SnapshotIDs[0] {
+		t.Errorf("latest = %+v, want the full snapshot %d, not the targeted %d", latest, full.SnapshotIDs[0], targeted.SnapshotIDs[0])
+	}
+}
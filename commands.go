@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/illenko/whodidthis/archive"
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/storage"
+)
+
+// commands work on the database of the configured instance instead of starting the server,
+// e.g. `whodidthis export -o incident.json 42`.
+var commands = map[string]func(args []string) error{
+	"export": exportCommand,
+	"import": importCommand,
+	"backup": backupCommand,
+}
+
+// openCommandStorage loads the config and opens its database. Only the storage section is
+// validated, so a config without any Prometheus source works. Logs go to stderr, leaving
+// stdout to the command's output.
+func openCommandStorage() (*config.Config, *storage.DB, error) {
+	cfg, err := config.LoadStorage(configPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.LogLevel()})))
+
+	db, err := openStorage(cfg.Storage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init database: %w", err)
+	}
+	return cfg, db, nil
+}
+
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", archive.FormatJSON, "archive format: json or ndjson")
+	output := fs.String("o", "-", "file to write the archive to, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: whodidthis export [-format json|ndjson] [-o file] scan-id...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one scan id is required")
+	}
+	ids := make([]int64, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid scan id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	if !archive.ValidFormat(*format) {
+		return fmt.Errorf("format must be json or ndjson")
+	}
+
+	_, db, err := openCommandStorage()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if *output != "-" {
+		if file, err = os.Create(*output); err != nil {
+			return fmt.Errorf("create archive: %w", err)
+		}
+		out = file
+	}
+
+	w := bufio.NewWriter(out)
+	err = archive.Export(ctx, storage.NewArchiveRepository(db), ids, *format, w)
+	if err == nil {
+		if err = w.Flush(); err != nil {
+			err = fmt.Errorf("write archive: %w", err)
+		}
+	}
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("write archive: %w", closeErr)
+		}
+		if err != nil {
+			os.Remove(*output)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("exported scans", "count", len(ids), "format", *format, "output", *output)
+	return nil
+}
+
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: whodidthis import [file]")
+		fmt.Fprintln(fs.Output(), "Reads a json or ndjson archive from file, or from stdin without one.")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("import takes at most one file")
+	}
+
+	var in io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open archive: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	_, db, err := openCommandStorage()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	result, err := archive.Import(ctx, storage.NewArchiveRepository(db), bufio.NewReader(in))
+	if result != nil {
+		for _, s := range result.Snapshots {
+			fmt.Printf("scan %d imported as %d\n", s.ArchiveID, s.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("imported scans", "snapshots", len(result.Snapshots), "analyses", result.Analyses, "skipped_analyses", result.SkippedAnalyses)
+	return nil
+}
+
+// backupCommand writes a backup of the database while the server may keep running on it.
+func backupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", "", "file to write the backup to (default: a new file in storage.backup.dir, rotating old backups)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: whodidthis backup [-o file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, db, err := openCommandStorage()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	path := *output
+	if path != "" {
+		err = db.Backup(ctx, path)
+	} else if cfg.Storage.Backup.Dir == "" {
+		return errors.New("set -o or storage.backup.dir")
+	} else {
+		path, err = db.BackupToDir(ctx, cfg.Storage.Backup.Dir, cfg.Storage.Backup.Keep)
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("backup completed", "file", path)
+	return nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const minimalConfig = `
+prometheus:
+  url: http://localhost:9090
+discovery:
+  service_label: job
+server:
+  port: 8080
+`
+
+func loadTestConfig(t *testing.T, yaml string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestLoadDetectionEnv(t *testing.T) {
+	t.Setenv("WDT_DETECTION_IGNORE_LABELS", "le,quantile")
+	t.Setenv("WDT_DETECTION_UNIQUE_VALUES_THRESHOLD", "500")
+
+	cfg := loadTestConfig(t, minimalConfig)
+
+	if want := []string{"le", "quantile"}; !slices.Equal(cfg.Detection.IgnoreLabels, want) {
+		t.Errorf("IgnoreLabels = %v, want %v", cfg.Detection.IgnoreLabels, want)
+	}
+	if cfg.Detection.UniqueValuesThreshold != 500 {
+		t.Errorf("UniqueValuesThreshold = %d, want 500", cfg.Detection.UniqueValuesThreshold)
+	}
+}
+
+func TestDropEstimatesOptIn(t *testing.T) {
+	cfg := loadTestConfig(t, minimalConfig)
+	if cfg.Scan.DropEstimateMinSeries != 0 {
+		t.Errorf("DropEstimateMinSeries = %d, want 0 (disabled) by default", cfg.Scan.DropEstimateMinSeries)
+	}
+
+	cfg.Scan.DropEstimateMinSeries = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate accepted a negative drop_estimate_min_series")
+	}
+}
+
+func TestAdminAPIOptIn(t *testing.T) {
+	cfg := loadTestConfig(t, minimalConfig)
+	if cfg.Server.AdminAPI {
+		t.Error("AdminAPI is enabled by default")
+	}
+
+	t.Setenv("WDT_SERVER_ADMIN_API", "true")
+	if cfg := loadTestConfig(t, minimalConfig); !cfg.Server.AdminAPI {
+		t.Error("WDT_SERVER_ADMIN_API=true did not enable the admin API")
+	}
+}
+
+func TestLoadStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(yaml string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("storage:\n  path: whodidthis.db\n  backup:\n    dir: backups\n")
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted a config without prometheus sources")
+	}
+	cfg, err := LoadStorage(path)
+	if err != nil {
+		t.Fatalf("LoadStorage: %v", err)
+	}
+	if cfg.Storage.Backup.Dir != "backups" || cfg.Storage.Backup.Keep != 7 {
+		t.Errorf("backup = %+v, want dir backups with the default keep", cfg.Storage.Backup)
+	}
+
+	write("storage:\n  driver: postgres\n")
+	if _, err := LoadStorage(path); err == nil || !strings.Contains(err.Error(), "storage.dsn") {
+		t.Errorf("LoadStorage error = %v, want one about storage.dsn", err)
+	}
+}
+
+func TestValidateFilterPatterns(t *testing.T) {
+	cfg := loadTestConfig(t, minimalConfig)
+
+	cfg.Scan.Filters.Metrics.Exclude = []string{"go_.*", "("}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "scan.filters.metrics.exclude[1]") {
+		t.Errorf("Validate error = %v, want one naming scan.filters.metrics.exclude[1]", err)
+	}
+}
+
+func TestProfileFiltersInheritance(t *testing.T) {
+	cfg := loadTestConfig(t, minimalConfig+`
+scan:
+  filters:
+    services:
+      exclude: ["canary-*"]
+  profiles:
+    - name: inherits
+      schedule: "@hourly"
+    - name: everything
+      schedule: "@daily"
+      filters: {}
+    - name: own
+      schedule: "@daily"
+      filters:
+        metrics:
+          include: ["http_*"]
+`)
+
+	tests := []struct {
+		profile         string
+		servicesExclude []string
+		metricsInclude  []string
+	}{
+		{"inherits", []string{"canary-*"}, nil},
+		{"everything", nil, nil},
+		{"own", nil, []string{"http_*"}},
+	}
+	for i, tt := range tests {
+		p := cfg.Scan.Profiles[i]
+		if p.Name != tt.profile || p.Filters == nil {
+			t.Fatalf("profile %d = %+v, want %s with filters set", i, p, tt.profile)
+		}
+		if !slices.Equal(p.Filters.Services.Exclude, tt.servicesExclude) {
+			t.Errorf("%s services exclude = %v, want %v", tt.profile, p.Filters.Services.Exclude, tt.servicesExclude)
+		}
+		if !slices.Equal(p.Filters.Metrics.Include, tt.metricsInclude) {
+			t.Errorf("%s metrics include = %v, want %v", tt.profile, p.Filters.Metrics.Include, tt.metricsInclude)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+// Detector flags exploding labels in a snapshot using deterministic rules,
+// independently of the AI analyzer.
+type Detector struct {
+	cfg          config.DetectionConfig
+	ignoreLabels map[string]struct{}
+	snapshots    storage.SnapshotsRepo
+	labels       storage.LabelsRepo
+	findings     storage.FindingsRepo
+	logger       *slog.Logger
+}
+
+type Config struct {
+	Detection config.DetectionConfig
+	Snapshots storage.SnapshotsRepo
+	Labels    storage.LabelsRepo
+	Findings  storage.FindingsRepo
+}
+
+func New(cfg Config) *Detector {
+	ignore := make(map[string]struct{}, len(cfg.Detection.IgnoreLabels))
+	for _, name := range cfg.Detection.IgnoreLabels {
+		ignore[name] = struct{}{}
+	}
+
+	return &Detector{
+		cfg:          cfg.Detection,
+		ignoreLabels: ignore,
+		snapshots:    cfg.Snapshots,
+		labels:       cfg.Labels,
+		findings:     cfg.Findings,
+		logger:       slog.Default().With("component", "detector"),
+	}
+}
+
+// Run evaluates all rules against a snapshot, compared with the snapshot before it,
+// and stores the findings.
+func (d *Detector) Run(ctx context.Context, snapshotID int64) ([]*models.LabelFinding, error) {
+	current, err := d.labels.ListBySnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("list labels for snapshot %d: %w", snapshotID, err)
+	}
+
+	previousCounts := make(map[labelKey]int)
+	previous, err := d.snapshots.GetPrevious(ctx, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("get previous snapshot: %w", err)
+	}
+	if previous != nil {
+		previousLabels, err := d.labels.ListBySnapshot(ctx, previous.ID)
+		if err != nil {
+			return nil, fmt.Errorf("list labels for snapshot %d: %w", previous.ID, err)
+		}
+		for _, l := range previousLabels {
+			previousCounts[keyOf(l)] = l.UniqueValuesCount
+		}
+	}
+
+	var findings []*models.LabelFinding
+	for _, l := range current {
+		if _, ignored := d.ignoreLabels[l.LabelName]; ignored {
+			continue
+		}
+
+		prev, hasPrev := previousCounts[keyOf(l)]
+		for _, f := range d.evaluate(l, prev, hasPrev) {
+			f.SnapshotID = snapshotID
+			findings = append(findings, f)
+		}
+	}
+
+	if err := d.findings.Replace(ctx, snapshotID, findings); err != nil {
+		return nil, fmt.Errorf("store findings: %w", err)
+	}
+
+	d.logger.Info("label detection complete", "snapshot_id", snapshotID, "findings", len(findings))
+
+	return findings, nil
+}
+
+type labelKey struct {
+	service string
+	metric  string
+	label   string
+}
+
+func keyOf(l models.SnapshotLabel) labelKey {
+	return labelKey{service: l.ServiceName, metric: l.MetricName, label: l.LabelName}
+}
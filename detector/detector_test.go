@@ -0,0 +1,69 @@
+package detector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+type fakeSnapshots struct {
+	storage.SnapshotsRepo
+	previous *models.Snapshot
+}
+
+func (f *fakeSnapshots) GetPrevious(context.Context, int64) (*models.Snapshot, error) {
+	return f.previous, nil
+}
+
+type fakeLabels struct {
+	storage.LabelsRepo
+	bySnapshot map[int64][]models.SnapshotLabel
+}
+
+func (f *fakeLabels) ListBySnapshot(_ context.Context, snapshotID int64) ([]models.SnapshotLabel, error) {
+	return f.bySnapshot[snapshotID], nil
+}
+
+type fakeFindings struct {
+	storage.FindingsRepo
+	stored map[int64][]*models.LabelFinding
+}
+
+func (f *fakeFindings) Replace(_ context.Context, snapshotID int64, findings []*models.LabelFinding) error {
+	f.stored[snapshotID] = findings
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	detection := testDetection
+	detection.IgnoreLabels = []string{"le"}
+
+	findings := &fakeFindings{stored: make(map[int64][]*models.LabelFinding)}
+	d := New(Config{
+		Detection: detection,
+		Snapshots: &fakeSnapshots{previous: &models.Snapshot{ID: 1}},
+		Labels: &fakeLabels{bySnapshot: map[int64][]models.SnapshotLabel{
+			1: {label("path", 30)},
+			2: {label("path", 60), label("le", 500), label("method", 5)},
+		}},
+		Findings: findings,
+	})
+
+	got, err := d.Run(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("findings = %+v, want only the growth of path", got)
+	}
+	f := got[0]
+	if f.Rule != models.FindingRuleRapidGrowth || f.LabelName != "path" || f.PreviousUniqueValues != 30 || f.SnapshotID != 2 {
+		t.Errorf("finding = %+v", f)
+	}
+	if len(findings.stored[2]) != 1 {
+		t.Errorf("stored %d findings for snapshot 2, want 1", len(findings.stored[2]))
+	}
+}
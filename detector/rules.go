@@ -0,0 +1,113 @@
+package detector
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+const maxExamples = 3
+
+type idPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// idPatterns are checked in order; a sample value counts towards the first pattern it matches.
+var idPatterns = []idPattern{
+	{"uuid", regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)},
+	{"timestamp", regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2})?\S*)?|1\d{9}(\d{3})?)$`)},
+	{"long_number", regexp.MustCompile(`^\d{7,}$`)},
+	{"email", regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)},
+	{"url_with_id", regexp.MustCompile(`/(\d{3,}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})(/|\?|$)`)},
+	{"hex_id", regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)},
+	{"prefixed_id", regexp.MustCompile(`^[A-Za-z]{2,}[_-][A-Za-z0-9]*\d[A-Za-z0-9]{3,}$`)},
+}
+
+func (d *Detector) evaluate(l models.SnapshotLabel, previous int, hasPrevious bool) []*models.LabelFinding {
+	var findings []*models.LabelFinding
+
+	newFinding := func(rule models.FindingRule, severity models.FindingSeverity, message string) *models.LabelFinding {
+		return &models.LabelFinding{
+			ServiceName:  l.ServiceName,
+			MetricName:   l.MetricName,
+			LabelName:    l.LabelName,
+			Rule:         rule,
+			Severity:     severity,
+			UniqueValues: l.UniqueValuesCount,
+			Message:      message,
+		}
+	}
+
+	threshold := d.cfg.UniqueValuesThreshold
+	if l.UniqueValuesCount >= threshold {
+		severity := models.FindingSeverityWarning
+		if l.UniqueValuesCount >= threshold*10 {
+			severity = models.FindingSeverityCritical
+		}
+		findings = append(findings, newFinding(models.FindingRuleHighCardinality, severity,
+			fmt.Sprintf("label %s has %d unique values (threshold %d)", l.LabelName, l.UniqueValuesCount, threshold)))
+	}
+
+	if hasPrevious && previous > 0 && l.UniqueValuesCount >= d.cfg.GrowthMinUniqueValues {
+		growth := float64(l.UniqueValuesCount-previous) / float64(previous) * 100
+		if growth >= d.cfg.GrowthPercent {
+			f := newFinding(models.FindingRuleRapidGrowth, models.FindingSeverityWarning,
+				fmt.Sprintf("label %s grew from %d to %d unique values (+%.0f%%)", l.LabelName, previous, l.UniqueValuesCount, growth))
+			f.PreviousUniqueValues = previous
+			findings = append(findings, f)
+		}
+	}
+
+	if l.UniqueValuesCount >= d.cfg.PatternMinUniqueValues {
+		if pattern, examples := matchIDPattern(l.SampleValues); pattern != "" {
+			severity := models.FindingSeverityWarning
+			if l.UniqueValuesCount >= threshold {
+				severity = models.FindingSeverityCritical
+			}
+			f := newFinding(models.FindingRuleIDPattern, severity,
+				fmt.Sprintf("label %s has ID-like values (%s)", l.LabelName, pattern))
+			f.Pattern = pattern
+			f.Examples = examples
+			findings = append(findings, f)
+		}
+	}
+
+	return findings
+}
+
+// matchIDPattern returns the most common ID-like pattern among the sample values,
+// provided it matches at least half of them.
+func matchIDPattern(values []string) (string, []string) {
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	matches := make(map[string][]string)
+	for _, v := range values {
+		for _, p := range idPatterns {
+			if p.re.MatchString(v) {
+				matches[p.name] = append(matches[p.name], v)
+				break
+			}
+		}
+	}
+
+	var best string
+	for _, p := range idPatterns {
+		if len(matches[p.name]) > len(matches[best]) {
+			best = p.name
+		}
+	}
+
+	if best == "" || len(matches[best])*2 < len(values) {
+		return "", nil
+	}
+
+	examples := matches[best]
+	if len(examples) > maxExamples {
+		examples = examples[:maxExamples]
+	}
+	return best, examples
+}
@@ -0,0 +1,146 @@
+package detector
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/models"
+)
+
+var testDetection = config.DetectionConfig{
+	UniqueValuesThreshold:  100,
+	GrowthPercent:          50,
+	GrowthMinUniqueValues:  20,
+	PatternMinUniqueValues: 10,
+}
+
+func label(name string, unique int, samples ...string) models.SnapshotLabel {
+	return models.SnapshotLabel{
+		ServiceName: "api",
+		MetricName:  "http_requests_total",
+		LabelSnapshot: models.LabelSnapshot{
+			LabelName:         name,
+			UniqueValuesCount: unique,
+			SampleValues:      samples,
+		},
+	}
+}
+
+type ruleResult struct {
+	rule     models.FindingRule
+	severity models.FindingSeverity
+}
+
+func TestEvaluate(t *testing.T) {
+	d := New(Config{Detection: testDetection})
+
+	tests := []struct {
+		name        string
+		label       models.SnapshotLabel
+		previous    int
+		hasPrevious bool
+		want        []ruleResult
+	}{
+		{
+			name:  "below every threshold",
+			label: label("method", 5, "GET", "POST"),
+		},
+		{
+			name:  "high cardinality",
+			label: label("path", 150, "/a", "/b"),
+			want:  []ruleResult{{models.FindingRuleHighCardinality, models.FindingSeverityWarning}},
+		},
+		{
+			name:  "ten times the threshold is critical",
+			label: label("path", 1000, "/a", "/b"),
+			want:  []ruleResult{{models.FindingRuleHighCardinality, models.FindingSeverityCritical}},
+		},
+		{
+			name:        "rapid growth",
+			label:       label("path", 40, "/a"),
+			previous:    20,
+			hasPrevious: true,
+			want:        []ruleResult{{models.FindingRuleRapidGrowth, models.FindingSeverityWarning}},
+		},
+		{
+			name:        "growth below the percentage",
+			label:       label("path", 25, "/a"),
+			previous:    20,
+			hasPrevious: true,
+		},
+		{
+			name:        "growth of a small label",
+			label:       label("path", 15, "/a"),
+			previous:    5,
+			hasPrevious: true,
+		},
+		{
+			name:  "growth without a previous snapshot",
+			label: label("path", 40, "/a"),
+		},
+		{
+			name:  "id pattern",
+			label: label("user_id", 30, "550e8400-e29b-41d4-a716-446655440000", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+			want:  []ruleResult{{models.FindingRuleIDPattern, models.FindingSeverityWarning}},
+		},
+		{
+			name:  "id pattern above the threshold is critical",
+			label: label("user_id", 200, "550e8400-e29b-41d4-a716-446655440000"),
+			want: []ruleResult{
+				{models.FindingRuleHighCardinality, models.FindingSeverityWarning},
+				{models.FindingRuleIDPattern, models.FindingSeverityCritical},
+			},
+		},
+		{
+			name:  "id pattern of a small label",
+			label: label("user_id", 5, "550e8400-e29b-41d4-a716-446655440000"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []ruleResult
+			for _, f := range d.evaluate(tt.label, tt.previous, tt.hasPrevious) {
+				got = append(got, ruleResult{f.Rule, f.Severity})
+				if f.LabelName != tt.label.LabelName || f.UniqueValues != tt.label.UniqueValuesCount {
+					t.Errorf("finding %+v does not describe the label", f)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("findings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchIDPattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		want     string
+		examples int
+	}{
+		{"none", nil, "", 0},
+		{"uuid", []string{"550e8400-e29b-41d4-a716-446655440000", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}, "uuid", 2},
+		{"timestamp", []string{"2026-03-01T12:00:00Z", "1767225600"}, "timestamp", 2},
+		{"long number", []string{"12345678", "98765432"}, "long_number", 2},
+		{"email", []string{"a@example.com", "b@example.org"}, "email", 2},
+		{"url with id", []string{"/users/12345/orders", "/users/67890"}, "url_with_id", 2},
+		{"hex id", []string{"deadbeefcafebabe1234", "0123456789abcdef"}, "hex_id", 2},
+		{"prefixed id", []string{"req_8f3k2j9d", "ord-4k2j8h3g"}, "prefixed_id", 2},
+		{"examples are capped", []string{"11111111", "22222222", "33333333", "44444444"}, "long_number", maxExamples},
+		{"half the values match", []string{"12345678", "GET"}, "long_number", 1},
+		{"less than half match", []string{"12345678", "GET", "POST"}, "", 0},
+		{"plain values", []string{"GET", "POST", "/api/users"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, examples := matchIDPattern(tt.values)
+			if got != tt.want || len(examples) != tt.examples {
+				t.Errorf("matchIDPattern = %q with %d examples, want %q with %d", got, len(examples), tt.want, tt.examples)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package exporter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/scheduler"
+	"github.com/illenko/whodidthis/storage"
+)
+
+const collectTimeout = 10 * time.Second
+
+var (
+	totalSeriesDesc = prometheus.NewDesc(
+		"whodidthis_total_series",
+		"Total series in the latest snapshot.",
+		[]string{"source", "tenant"}, nil,
+	)
+	totalServicesDesc = prometheus.NewDesc(
+		"whodidthis_total_services",
+		"Number of services in the latest snapshot.",
+		[]string{"source", "tenant"}, nil,
+	)
+	serviceSeriesDesc = prometheus.NewDesc(
+		"whodidthis_service_series",
+		"Series per service in the latest snapshot.",
+		[]string{"source", "tenant", "service"}, nil,
+	)
+	serviceMetricsDesc = prometheus.NewDesc(
+		"whodidthis_service_metrics",
+		"Metric names per service in the latest snapshot.",
+		[]string{"source", "tenant", "service"}, nil,
+	)
+	metricSeriesDesc = prometheus.NewDesc(
+		"whodidthis_metric_series",
+		"Series per metric in the latest snapshot.",
+		[]string{"source", "tenant", "service", "metric"}, nil,
+	)
+	snapshotTimestampDesc = prometheus.NewDesc(
+		"whodidthis_snapshot_timestamp_seconds",
+		"Collection time of the latest snapshot.",
+		[]string{"source", "tenant"}, nil,
+	)
+	scanRunningDesc = prometheus.NewDesc(
+		"whodidthis_scan_running",
+		"Whether a scan is currently running.",
+		nil, nil,
+	)
+	lastScanSuccessDesc = prometheus.NewDesc(
+		"whodidthis_last_scan_success",
+		"Whether the last scan completed without error.",
+		nil, nil,
+	)
+	lastScanTimestampDesc = prometheus.NewDesc(
+		"whodidthis_last_scan_timestamp_seconds",
+		"Start time of the last scan run by this process.",
+		nil, nil,
+	)
+	lastScanDurationDesc = prometheus.NewDesc(
+		"whodidthis_last_scan_duration_seconds",
+		"Duration of the latest snapshot's scan.",
+		[]string{"source", "tenant"}, nil,
+	)
+	exporterUpDesc = prometheus.NewDesc(
+		"whodidthis_exporter_up",
+		"Whether the latest snapshot could be read from the database.",
+		nil, nil,
+	)
+)
+
+type StatusProvider interface {
+	GetStatus() scheduler.ScanStatus
+}
+
+// Exporter exposes the latest snapshot and scan health as Prometheus gauges.
+// Values are read from storage on every scrape.
+type Exporter struct {
+	snapshots       storage.SnapshotsRepo
+	services        storage.ServicesRepo
+	metrics         storage.MetricsRepo
+	status          StatusProvider
+	metricMinSeries int
+	logger          *slog.Logger
+}
+
+type Config struct {
+	Snapshots       storage.SnapshotsRepo
+	Services        storage.ServicesRepo
+	Metrics         storage.MetricsRepo
+	Status          StatusProvider
+	MetricMinSeries int
+}
+
+func New(cfg Config) *Exporter {
+	return &Exporter{
+		snapshots:       cfg.Snapshots,
+		services:        cfg.Services,
+		metrics:         cfg.Metrics,
+		status:          cfg.Status,
+		metricMinSeries: cfg.MetricMinSeries,
+		logger:          slog.Default().With("component", "exporter"),
+	}
+}
+
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- totalSeriesDesc
+	ch <- totalServicesDesc
+	ch <- serviceSeriesDesc
+	ch <- serviceMetricsDesc
+	ch <- metricSeriesDesc
+	ch <- snapshotTimestampDesc
+	ch <- scanRunningDesc
+	ch <- lastScanSuccessDesc
+	ch <- lastScanTimestampDesc
+	ch <- lastScanDurationDesc
+	ch <- exporterUpDesc
+}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	if e.status != nil {
+		e.collectStatus(ch)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	if err := e.collectSnapshot(ctx, ch); err != nil {
+		e.logger.Error("failed to collect snapshot metrics", "error", err)
+		ch <- prometheus.MustNewConstMetric(exporterUpDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(exporterUpDesc, prometheus.GaugeValue, 1)
+}
+
+func (e *Exporter) collectStatus(ch chan<- prometheus.Metric) {
+	status := e.status.GetStatus()
+
+	ch <- prometheus.MustNewConstMetric(scanRunningDesc, prometheus.GaugeValue, boolToFloat(status.Running))
+
+	if !status.LastScanAt.IsZero() {
+		ch <- prometheus.MustNewConstMetric(lastScanSuccessDesc, prometheus.GaugeValue, boolToFloat(status.LastError == ""))
+		ch <- prometheus.MustNewConstMetric(lastScanTimestampDesc, prometheus.GaugeValue, float64(status.LastScanAt.Unix()))
+	}
+}
+
+// collectSnapshot exports the latest snapshot of every source and tenant.
+func (e *Exporter) collectSnapshot(ctx context.Context, ch chan<- prometheus.Metric) error {
+	scopes, err := e.snapshots.ListScopes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		latest, err := e.snapshots.GetLatest(ctx, scope)
+		if err != nil {
+			return err
+		}
+		if latest == nil {
+			continue
+		}
+		if err := e.collectLatest(ctx, ch, latest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) collectLatest(ctx context.Context, ch chan<- prometheus.Metric, latest *models.Snapshot) error {
+	source, tenant := latest.Source, latest.Tenant
+
+	ch <- prometheus.MustNewConstMetric(totalSeriesDesc, prometheus.GaugeValue, float64(latest.TotalSeries), source, tenant)
+	ch <- prometheus.MustNewConstMetric(totalServicesDesc, prometheus.GaugeValue, float64(latest.TotalServices), source, tenant)
+	ch <- prometheus.MustNewConstMetric(snapshotTimestampDesc, prometheus.GaugeValue, float64(latest.CollectedAt.Unix()), source, tenant)
+	ch <- prometheus.MustNewConstMetric(lastScanDurationDesc, prometheus.GaugeValue, float64(latest.ScanDurationMs)/1000, source, tenant)
+
+	services, err := e.services.List(ctx, latest.ID, storage.ServiceListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		ch <- prometheus.MustNewConstMetric(serviceSeriesDesc, prometheus.GaugeValue, float64(svc.TotalSeries), source, tenant, svc.ServiceName)
+		ch <- prometheus.MustNewConstMetric(serviceMetricsDesc, prometheus.GaugeValue, float64(svc.MetricCount), source, tenant, svc.ServiceName)
+	}
+
+	metrics, err := e.metrics.ListTop(ctx, latest.ID, storage.TopMetricsOptions{MinSeries: e.metricMinSeries})
+	if err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		ch <- prometheus.MustNewConstMetric(metricSeriesDesc, prometheus.GaugeValue, float64(m.SeriesCount), source, tenant, m.ServiceName, m.MetricName)
+	}
+
+	return nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,189 @@
+package exporter
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/scheduler"
+	"github.com/illenko/whodidthis/storage"
+)
+
+var testTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type fakeStatus struct {
+	status scheduler.ScanStatus
+}
+
+func (f fakeStatus) GetStatus() scheduler.ScanStatus { return f.status }
+
+type testService struct {
+	name    string
+	metrics map[string]int // series by metric
+}
+
+// seedSnapshot imports a completed snapshot of the given services and returns its ID.
+func seedSnapshot(t *testing.T, repo *storage.ArchiveRepository, snapshot models.Snapshot, services ...testService) int64 {
+	t.Helper()
+	if snapshot.Status == "" {
+		snapshot.Status = models.ScanRunStatusCompleted
+	}
+	archived := models.ArchivedSnapshot{Snapshot: snapshot}
+	for _, svc := range services {
+		s := models.ArchivedService{ServiceSnapshot: models.ServiceSnapshot{ServiceName: svc.name, MetricCount: len(svc.metrics)}}
+		for name, series := range svc.metrics {
+			s.TotalSeries += series
+			s.Metrics = append(s.Metrics, models.ArchivedMetric{MetricSnapshot: models.MetricSnapshot{MetricName: name, SeriesCount: series}})
+		}
+		archived.Snapshot.TotalServices++
+		archived.Snapshot.TotalSeries += int64(s.TotalSeries)
+		archived.Services = append(archived.Services, s)
+	}
+	id, err := repo.Import(context.Background(), &archived)
+	if err != nil {
+		t.Fatalf("seed snapshot: %v", err)
+	}
+	return id
+}
+
+func newTestExporter(t *testing.T, metricMinSeries int, status StatusProvider) (*Exporter, *storage.ArchiveRepository) {
+	t.Helper()
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return New(Config{
+		Snapshots:       storage.NewSnapshotsRepository(db),
+		Services:        storage.NewServicesRepository(db),
+		Metrics:         storage.NewMetricsRepository(db),
+		Status:          status,
+		MetricMinSeries: metricMinSeries,
+	}), storage.NewArchiveRepository(db)
+}
+
+func TestCollect(t *testing.T) {
+	e, repo := newTestExporter(t, 10, fakeStatus{scheduler.ScanStatus{LastScanAt: testTime.Add(2 * time.Hour)}})
+
+	// Only the latest full snapshot of each scope is exported.
+	seedSnapshot(t, repo, models.Snapshot{Source: "prod", CollectedAt: testTime},
+		testService{"api", map[string]int{"http_requests_total": 50}},
+	)
+	seedSnapshot(t, repo, models.Snapshot{Source: "prod", CollectedAt: testTime.Add(time.Hour), ScanDurationMs: 1500},
+		testService{"api", map[string]int{"http_requests_total": 100, "up": 1}},
+		testService{"worker", map[string]int{"jobs_total": 10}},
+	)
+	seedSnapshot(t, repo, models.Snapshot{Source: "prod", ServiceFilter: "api", CollectedAt: testTime.Add(2 * time.Hour)},
+		testService{"api", map[string]int{"http_requests_total": 999}},
+	)
+	seedSnapshot(t, repo, models.Snapshot{Source: "staging", Tenant: "team-a", CollectedAt: testTime.Add(time.Hour), ScanDurationMs: 500},
+		testService{"api", map[string]int{"http_requests_total": 20, "debug_info": 5}},
+	)
+
+	want := `
+# HELP whodidthis_exporter_up Whether the latest snapshot could be read from the database.
+# TYPE whodidthis_exporter_up gauge
+whodidthis_exporter_up 1
+# HELP whodidthis_last_scan_duration_seconds Duration of the latest snapshot's scan.
+# TYPE whodidthis_last_scan_duration_seconds gauge
+whodidthis_last_scan_duration_seconds{source="prod",tenant=""} 1.5
+whodidthis_last_scan_duration_seconds{source="staging",tenant="team-a"} 0.5
+# HELP whodidthis_last_scan_success Whether the last scan completed without error.
+# TYPE whodidthis_last_scan_success gauge
+whodidthis_last_scan_success 1
+# HELP whodidthis_last_scan_timestamp_seconds Start time of the last scan run by this process.
+# TYPE whodidthis_last_scan_timestamp_seconds gauge
+whodidthis_last_scan_timestamp_seconds 1.7723736e+09
+# HELP whodidthis_metric_series Series per metric in the latest snapshot.
+# TYPE whodidthis_metric_series gauge
+whodidthis_metric_series{metric="http_requests_total",service="api",source="prod",tenant=""} 100
+whodidthis_metric_series{metric="jobs_total",service="worker",source="prod",tenant=""} 10
+whodidthis_metric_series{metric="http_requests_total",service="api",source="staging",tenant="team-a"} 20
+# HELP whodidthis_scan_running Whether a scan is currently running.
+# TYPE whodidthis_scan_running gauge
+whodidthis_scan_running 0
+# HELP whodidthis_service_metrics Metric names per service in the latest snapshot.
+# TYPE whodidthis_service_metrics gauge
+whodidthis_service_metrics{service="api",source="prod",tenant=""} 2
+whodidthis_service_metrics{service="worker",source="prod",tenant=""} 1
+whodidthis_service_metrics{service="api",source="staging",tenant="team-a"} 2
+# HELP whodidthis_service_series Series per service in the latest snapshot.
+# TYPE whodidthis_service_series gauge
+whodidthis_service_series{service="api",source="prod",tenant=""} 101
+whodidthis_service_series{service="worker",source="prod",tenant=""} 10
+whodidthis_service_series{service="api",source="staging",tenant="team-a"} 25
+# HELP whodidthis_snapshot_timestamp_seconds Collection time of the latest snapshot.
+# TYPE whodidthis_snapshot_timestamp_seconds gauge
+whodidthis_snapshot_timestamp_seconds{source="prod",tenant=""} 1.77237e+09
+whodidthis_snapshot_timestamp_seconds{source="staging",tenant="team-a"} 1.77237e+09
+# HELP whodidthis_total_series Total series in the latest snapshot.
+# TYPE whodidthis_total_series gauge
+whodidthis_total_series{source="prod",tenant=""} 111
+whodidthis_total_series{source="staging",tenant="team-a"} 25
+# HELP whodidthis_total_services Number of services in the latest snapshot.
+# TYPE whodidthis_total_services gauge
+whodidthis_total_services{source="prod",tenant=""} 2
+whodidthis_total_services{source="staging",tenant="team-a"} 1
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectMetricMinSeries(t *testing.T) {
+	tests := []struct {
+		minSeries int
+		want      string
+	}{
+		{0, `
+whodidthis_metric_series{metric="http_requests_total",service="api",source="prod",tenant=""} 100
+whodidthis_metric_series{metric="up",service="api",source="prod",tenant=""} 1
+whodidthis_metric_series{metric="jobs_total",service="worker",source="prod",tenant=""} 10
+`},
+		{10, `
+whodidthis_metric_series{metric="http_requests_total",service="api",source="prod",tenant=""} 100
+whodidthis_metric_series{metric="jobs_total",service="worker",source="prod",tenant=""} 10
+`},
+		{101, ``},
+	}
+	for _, tt := range tests {
+		e, repo := newTestExporter(t, tt.minSeries, nil)
+		seedSnapshot(t, repo, models.Snapshot{Source: "prod", CollectedAt: testTime},
+			testService{"api", map[string]int{"http_requests_total": 100, "up": 1}},
+			testService{"worker", map[string]int{"jobs_total": 10}},
+		)
+
+		want := ""
+		if tt.want != "" {
+			want = `
+# HELP whodidthis_metric_series Series per metric in the latest snapshot.
+# TYPE whodidthis_metric_series gauge` + tt.want
+		}
+		if err := testutil.CollectAndCompare(e, strings.NewReader(want), "whodidthis_metric_series"); err != nil {
+			t.Errorf("metric_min_series %d: %v", tt.minSeries, err)
+		}
+	}
+}
+
+func TestCollectEmptyDatabase(t *testing.T) {
+	e, _ := newTestExporter(t, 0, fakeStatus{scheduler.ScanStatus{Running: true}})
+
+	// Without snapshots or a finished scan, only the scan and exporter state is exported.
+	want := `
+# HELP whodidthis_exporter_up Whether the latest snapshot could be read from the database.
+# TYPE whodidthis_exporter_up gauge
+whodidthis_exporter_up 1
+# HELP whodidthis_scan_running Whether a scan is currently running.
+# TYPE whodidthis_scan_running gauge
+whodidthis_scan_running 1
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
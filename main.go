@@ -14,9 +14,14 @@ import (
 	"github.com/illenko/whodidthis/api/handler"
 	"github.com/illenko/whodidthis/collector"
 	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/detector"
+	"github.com/illenko/whodidthis/exporter"
+	"github.com/illenko/whodidthis/notifier"
 	"github.com/illenko/whodidthis/prometheus"
 	"github.com/illenko/whodidthis/scheduler"
 	"github.com/illenko/whodidthis/storage"
+	"github.com/illenko/whodidthis/telemetry"
+	"github.com/robfig/cron/v3"
 )
 
 var (
@@ -26,27 +31,43 @@ var (
 )
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	if len(os.Args) > 1 && commands[os.Args[1]] != nil {
+		err = commands[os.Args[1]](os.Args[2:])
+	} else {
+		err = run()
+	}
+	if err != nil {
 		slog.Error("fatal error", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "config.yaml"
+func configPath() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	return "config.yaml"
+}
+
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
 	}
+	return cfg, nil
+}
 
-	cfg, err := config.Load(configPath)
+func run() error {
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("load config: %w", err)
+		return err
 	}
 
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel()})))
 	slog.Info("starting whodidthis", "version", version, "commit", commit, "built", buildTime)
 
-	db, err := storage.New(cfg.Storage.Path)
+	db, err := openStorage(cfg.Storage)
 	if err != nil {
 		return fmt.Errorf("init database: %w", err)
 	}
@@ -60,30 +81,132 @@ func run() error {
 	servicesRepo := storage.NewServicesRepository(db)
 	metricsRepo := storage.NewMetricsRepository(db)
 	labelsRepo := storage.NewLabelsRepository(db)
+	diffRepo := storage.NewDiffRepository(db)
+	findingsRepo := storage.NewFindingsRepository(db)
+	tsdbStatsRepo := storage.NewTSDBStatsRepository(db)
+	scanRunsRepo := storage.NewScanRunsRepository(db)
 
-	promClient, err := prometheus.NewClient(prometheus.Config{
-		URL:      cfg.Prometheus.URL,
-		Username: cfg.Prometheus.Username,
-		Password: cfg.Prometheus.Password,
-		Timeout:  cfg.Prometheus.Timeout,
-	})
-	if err != nil {
-		return fmt.Errorf("create prometheus client: %w", err)
+	var sources []collector.Source
+	var promClients []prometheus.MetricsClient
+	for _, src := range cfg.Sources {
+		detectCtx, detectCancel := context.WithTimeout(context.Background(), src.Timeout)
+		promClient, flavor, err := prometheus.NewMetricsClient(detectCtx, prometheus.Config{
+			URL:             src.URL,
+			Username:        src.Username,
+			Password:        src.Password,
+			BearerToken:     src.BearerToken,
+			BearerTokenFile: src.BearerTokenFile,
+			OAuth2:          oauth2Config(src.OAuth2),
+			TLS: prometheus.TLSConfig{
+				CAFile:             src.TLS.CAFile,
+				CertFile:           src.TLS.CertFile,
+				KeyFile:            src.TLS.KeyFile,
+				ServerName:         src.TLS.ServerName,
+				InsecureSkipVerify: src.TLS.InsecureSkipVerify,
+			},
+			Headers: src.Headers,
+			Timeout: src.Timeout,
+			Flavor:  src.Flavor,
+			Tenants: src.Tenants,
+			Retry: prometheus.RetryConfig{
+				MaxAttempts:    cfg.Prometheus.Retry.MaxAttempts,
+				InitialBackoff: cfg.Prometheus.Retry.InitialBackoff,
+				MaxBackoff:     cfg.Prometheus.Retry.MaxBackoff,
+			},
+			CircuitBreaker: prometheus.CircuitBreakerConfig{
+				FailureThreshold: cfg.Prometheus.CircuitBreaker.FailureThreshold,
+				Cooldown:         cfg.Prometheus.CircuitBreaker.Cooldown,
+			},
+			Budget: prometheus.BudgetConfig{
+				MaxQPS:              cfg.Prometheus.Budget.MaxQPS,
+				MaxConcurrentSeries: cfg.Prometheus.Budget.MaxConcurrentSeries,
+			},
+		})
+		detectCancel()
+		if err != nil && promClient == nil {
+			return fmt.Errorf("create prometheus client for source %q: %w", src.Name, err)
+		}
+		if err != nil {
+			slog.Warn("falling back to generic prometheus client", "source", src.Name, "error", err)
+		}
+
+		sources = append(sources, collector.Source{
+			Name:         src.Name,
+			Client:       promClient,
+			ServiceLabel: src.ServiceLabel,
+			Tenants:      src.Tenants,
+		})
+		promClients = append(promClients, promClient)
+
+		slog.Info("prometheus source configured", "source", src.Name, "url", src.URL, "flavor", flavor, "tenants", src.Tenants)
 	}
 
 	coll := collector.NewCollector(
-		promClient,
+		sources,
 		snapshotsRepo,
 		servicesRepo,
 		metricsRepo,
 		labelsRepo,
+		tsdbStatsRepo,
+		scanRunsRepo,
 		cfg,
 	)
 
+	var profiles []scheduler.Profile
+	for _, p := range cfg.Scan.Profiles {
+		schedule, err := cron.ParseStandard(p.Schedule)
+		if err != nil {
+			return fmt.Errorf("parse schedule of scan profile %q: %w", p.Name, err)
+		}
+		profiles = append(profiles, scheduler.Profile{Schedule: schedule, Scan: collector.NewProfile(p)})
+
+		if p.Mode == config.ScanModeTSDB {
+			slog.Info("tsdb scan mode enabled: label details are skipped", "profile", p.Name, "tsdb_status_limit", cfg.Scan.TSDBStatusLimit)
+		}
+	}
+
+	var labelDetector *detector.Detector
+	if cfg.Detection.Enabled {
+		labelDetector = detector.New(detector.Config{
+			Detection: cfg.Detection,
+			Snapshots: snapshotsRepo,
+			Labels:    labelsRepo,
+			Findings:  findingsRepo,
+		})
+	}
+
+	var cardinalityNotifier *notifier.Notifier
+	if len(cfg.Notifications.Webhooks) > 0 {
+		cardinalityNotifier = notifier.New(notifier.Config{
+			Notifications: cfg.Notifications,
+			Snapshots:     snapshotsRepo,
+			Diff:          diffRepo,
+		})
+		slog.Info("notifications enabled", "webhooks", len(cfg.Notifications.Webhooks))
+	}
+
+	var retention []storage.RetentionTier
+	for _, tier := range cfg.Storage.RetentionTiers {
+		retention = append(retention, storage.RetentionTier{MaxAge: tier.MaxAge(), Resolution: tier.Resolution})
+	}
+
+	var backup *scheduler.Backup
+	if cfg.Storage.Backup.Schedule != "" {
+		schedule, err := cron.ParseStandard(cfg.Storage.Backup.Schedule)
+		if err != nil {
+			return fmt.Errorf("parse backup schedule: %w", err)
+		}
+		backup = &scheduler.Backup{Schedule: schedule, Dir: cfg.Storage.Backup.Dir, Keep: cfg.Storage.Backup.Keep}
+	}
+
 	sched := scheduler.New(coll, scheduler.Config{
-		Interval:  cfg.Scan.Interval,
-		Retention: cfg.RetentionDuration(),
+		Profiles:  profiles,
+		Retention: retention,
+		Backup:    backup,
 		DB:        db,
+		Runs:      scanRunsRepo,
+		Detector:  labelDetector,
+		Notifier:  cardinalityNotifier,
 	})
 
 	analysisRepo := storage.NewAnalysisRepository(db)
@@ -106,12 +229,30 @@ func run() error {
 		slog.Warn("AI analysis disabled: WDT_GEMINI_API_KEY not set")
 	}
 
-	healthHandler := handler.NewHealthHandler(snapshotsRepo, db, promClient)
-	scansHandler := handler.NewScansHandler(snapshotsRepo, sched)
+	healthHandler := handler.NewHealthHandler(snapshotsRepo, db, promClients)
+	scansHandler := handler.NewScansHandler(snapshotsRepo, scanRunsRepo, sched)
 	analysisHandler := handler.NewAnalysisHandler(snapshotAnalyzer)
 	servicesHandler := handler.NewServicesHandler(servicesRepo)
-	metricsHandler := handler.NewMetricsHandler(servicesRepo, metricsRepo)
+	metricsHandler := handler.NewMetricsHandler(snapshotsRepo, servicesRepo, metricsRepo)
 	labelsHandler := handler.NewLabelsHandler(servicesRepo, metricsRepo, labelsRepo)
+	diffHandler := handler.NewDiffHandler(diffRepo, snapshotsRepo)
+	historyHandler := handler.NewHistoryHandler(servicesRepo, metricsRepo)
+	findingsHandler := handler.NewFindingsHandler(findingsRepo)
+	tsdbHandler := handler.NewTSDBHandler(tsdbStatsRepo)
+	archiveHandler := handler.NewArchiveHandler(storage.NewArchiveRepository(db), snapshotsRepo)
+	var adminHandler *handler.AdminHandler
+	if cfg.Server.AdminAPI {
+		adminHandler = handler.NewAdminHandler(db, cfg.Storage.Backup.Dir, cfg.Storage.Backup.Keep)
+	}
+
+	cardinalityExporter := exporter.New(exporter.Config{
+		Snapshots:       snapshotsRepo,
+		Services:        servicesRepo,
+		Metrics:         metricsRepo,
+		Status:          sched,
+		MetricMinSeries: cfg.Exporter.MetricMinSeries,
+	})
+	telemetry.Registry.MustRegister(cardinalityExporter)
 
 	server := api.NewServer(
 		healthHandler,
@@ -120,6 +261,13 @@ func run() error {
 		servicesHandler,
 		metricsHandler,
 		labelsHandler,
+		diffHandler,
+		historyHandler,
+		findingsHandler,
+		tsdbHandler,
+		archiveHandler,
+		adminHandler,
+		telemetry.Handler(),
 		api.ServerConfig{
 			Host: cfg.Server.Host,
 			Port: cfg.Server.Port,
@@ -148,3 +296,24 @@ func run() error {
 
 	return server.Start()
 }
+
+func openStorage(c config.StorageConfig) (*storage.DB, error) {
+	if c.Driver == config.StorageDriverPostgres {
+		return storage.NewPostgres(c.DSN, c.MaxOpenConns)
+	}
+	return storage.New(c.Path)
+}
+
+func oauth2Config(c *config.OAuth2Config) *prometheus.OAuth2Config {
+	if c == nil {
+		return nil
+	}
+	return &prometheus.OAuth2Config{
+		ClientID:         c.ClientID,
+		ClientSecret:     c.ClientSecret,
+		ClientSecretFile: c.ClientSecretFile,
+		TokenURL:         c.TokenURL,
+		Scopes:           c.Scopes,
+		EndpointParams:   c.EndpointParams,
+	}
+}
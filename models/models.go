@@ -3,11 +3,40 @@ package models
 import "time"
 
 type Snapshot struct {
-	ID             int64     `json:"id"`
-	CollectedAt    time.Time `json:"collected_at"`
-	ScanDurationMs int       `json:"duration_ms,omitempty"`
-	TotalServices  int       `json:"total_services"`
-	TotalSeries    int64     `json:"total_series"`
+	ID             int64         `json:"id"`
+	ScanRunID      int64         `json:"scan_run_id,omitempty"`
+	Source         string        `json:"source,omitempty"`
+	Tenant         string        `json:"tenant,omitempty"`
+	Status         ScanRunStatus `json:"status"`
+	ServiceFilter  string        `json:"service_filter,omitempty"` // set for targeted scans of some services
+	Profile        string        `json:"profile,omitempty"`        // scan profile that collected the snapshot
+	CollectedAt    time.Time     `json:"collected_at"`
+	ScanDurationMs int           `json:"duration_ms,omitempty"`
+	TotalServices  int           `json:"total_services"`
+	TotalSeries    int64         `json:"total_series"`
+	Baseline       *Baseline     `json:"baseline,omitempty"` // set when the snapshot is pinned
+}
+
+// Baseline names a pinned snapshot. Retention cleanup keeps pinned snapshots, and the default
+// baseline of a source and tenant is what its snapshots are compared with.
+type Baseline struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Default     bool      `json:"default"`
+	PinnedAt    time.Time `json:"pinned_at"`
+}
+
+// Scope names the source and tenant the snapshot was collected from, e.g. "prod-eu/team-a".
+// It is empty for a single unnamed, untenanted source.
+func (s *Snapshot) Scope() string {
+	switch {
+	case s.Source == "":
+		return s.Tenant
+	case s.Tenant == "":
+		return s.Source
+	default:
+		return s.Source + "/" + s.Tenant
+	}
 }
 
 type ServiceSnapshot struct {
@@ -32,6 +61,8 @@ type LabelSnapshot struct {
 	LabelName         string   `json:"name"`
 	UniqueValuesCount int      `json:"unique_values"`
 	SampleValues      []string `json:"sample_values,omitempty"`
+	// SeriesWithoutLabel is the metric's series count if this label were dropped; nil when not estimated.
+	SeriesWithoutLabel *int `json:"series_without_label,omitempty"`
 }
 
 type Overview struct {
@@ -47,6 +78,36 @@ type ScanStatus struct {
 	LastDuration string    `json:"last_duration,omitempty"`
 }
 
+// ScanRunStatus is the outcome of a scan run, and of each snapshot it produced.
+type ScanRunStatus string
+
+const (
+	ScanRunStatusRunning   ScanRunStatus = "running"
+	ScanRunStatusCompleted ScanRunStatus = "completed"
+	ScanRunStatusPartial   ScanRunStatus = "partial" // some targets, services or label fetches failed
+	ScanRunStatusFailed    ScanRunStatus = "failed"
+	ScanRunStatusCancelled ScanRunStatus = "cancelled"
+)
+
+type ScanRun struct {
+	ID         int64         `json:"id"`
+	Status     ScanRunStatus `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Failures   []ScanFailure `json:"failures,omitempty"`
+}
+
+// ScanFailure is a service that could not be scanned or, with MetricName set,
+// a metric whose labels could not be fetched.
+type ScanFailure struct {
+	ScanRunID   int64  `json:"scan_run_id"`
+	SnapshotID  int64  `json:"snapshot_id"`
+	ServiceName string `json:"service"`
+	MetricName  string `json:"metric,omitempty"`
+	Reason      string `json:"reason"`
+}
+
 type HealthStatus struct {
 	Status              string    `json:"status"`
 	PrometheusConnected bool      `json:"prometheus_connected"`
@@ -87,3 +148,172 @@ type AnalysisGlobalStatus struct {
 	PreviousSnapshotID int64  `json:"previous_snapshot_id,omitempty"`
 	Progress           string `json:"progress,omitempty"`
 }
+
+type DiffStatus string
+
+const (
+	DiffStatusAdded     DiffStatus = "added"
+	DiffStatusRemoved   DiffStatus = "removed"
+	DiffStatusChanged   DiffStatus = "changed"
+	DiffStatusUnchanged DiffStatus = "unchanged"
+)
+
+type SnapshotDiff struct {
+	FromSnapshotID     int64         `json:"from_snapshot_id"`
+	ToSnapshotID       int64         `json:"to_snapshot_id"`
+	FromCollectedAt    time.Time     `json:"from_collected_at"`
+	ToCollectedAt      time.Time     `json:"to_collected_at"`
+	FromTotalSeries    int64         `json:"from_total_series"`
+	ToTotalSeries      int64         `json:"to_total_series"`
+	SeriesDelta        int64         `json:"series_delta"`
+	SeriesDeltaPercent float64       `json:"series_delta_percent"`
+	AddedServices      []string      `json:"added_services"`
+	RemovedServices    []string      `json:"removed_services"`
+	UnchangedServices  int           `json:"unchanged_services"`
+	Services           []ServiceDiff `json:"services"`
+}
+
+type ServiceDiff struct {
+	Name               string       `json:"name"`
+	Status             DiffStatus   `json:"status"`
+	FromSeries         int          `json:"from_series"`
+	ToSeries           int          `json:"to_series"`
+	SeriesDelta        int          `json:"series_delta"`
+	SeriesDeltaPercent float64      `json:"series_delta_percent"`
+	FromMetricCount    int          `json:"from_metric_count"`
+	ToMetricCount      int          `json:"to_metric_count"`
+	AddedMetrics       []string     `json:"added_metrics,omitempty"`
+	RemovedMetrics     []string     `json:"removed_metrics,omitempty"`
+	UnchangedMetrics   int          `json:"unchanged_metrics"`
+	Metrics            []MetricDiff `json:"metrics,omitempty"`
+}
+
+type MetricDiff struct {
+	Name               string      `json:"name"`
+	Status             DiffStatus  `json:"status"`
+	FromSeries         int         `json:"from_series"`
+	ToSeries           int         `json:"to_series"`
+	SeriesDelta        int         `json:"series_delta"`
+	SeriesDeltaPercent float64     `json:"series_delta_percent"`
+	Labels             []LabelDiff `json:"labels,omitempty"`
+}
+
+type LabelDiff struct {
+	Name              string     `json:"name"`
+	Status            DiffStatus `json:"status"`
+	FromUniqueValues  int        `json:"from_unique_values"`
+	ToUniqueValues    int        `json:"to_unique_values"`
+	UniqueValuesDelta int        `json:"unique_values_delta"`
+}
+
+type TopMetric struct {
+	ServiceName         string  `json:"service"`
+	MetricName          string  `json:"name"`
+	SeriesCount         int     `json:"series_count"`
+	LabelCount          int     `json:"label_count"`
+	PreviousSeriesCount int     `json:"previous_series_count"`
+	SeriesDelta         int     `json:"series_delta"`
+	SeriesDeltaPercent  float64 `json:"series_delta_percent"`
+	New                 bool    `json:"new,omitempty"`
+}
+
+type ServiceHistoryPoint struct {
+	SnapshotID  int64     `json:"snapshot_id"`
+	Source      string    `json:"source,omitempty"`
+	Tenant      string    `json:"tenant,omitempty"`
+	CollectedAt time.Time `json:"collected_at"`
+	TotalSeries int       `json:"total_series"`
+	MetricCount int       `json:"metric_count"`
+}
+
+type MetricHistoryPoint struct {
+	SnapshotID  int64          `json:"snapshot_id"`
+	Source      string         `json:"source,omitempty"`
+	Tenant      string         `json:"tenant,omitempty"`
+	CollectedAt time.Time      `json:"collected_at"`
+	SeriesCount int            `json:"series_count"`
+	LabelCount  int            `json:"label_count"`
+	Labels      map[string]int `json:"labels"`
+}
+
+type SnapshotLabel struct {
+	ServiceName string `json:"service"`
+	MetricName  string `json:"metric"`
+	SeriesCount int    `json:"series_count"`
+	LabelSnapshot
+}
+
+type FindingRule string
+
+const (
+	FindingRuleHighCardinality FindingRule = "high_cardinality"
+	FindingRuleRapidGrowth     FindingRule = "rapid_growth"
+	FindingRuleIDPattern       FindingRule = "id_pattern"
+)
+
+type FindingSeverity string
+
+const (
+	FindingSeverityWarning  FindingSeverity = "warning"
+	FindingSeverityCritical FindingSeverity = "critical"
+)
+
+type LabelFinding struct {
+	ID                   int64           `json:"id"`
+	SnapshotID           int64           `json:"snapshot_id"`
+	ServiceName          string          `json:"service"`
+	MetricName           string          `json:"metric"`
+	LabelName            string          `json:"label"`
+	Rule                 FindingRule     `json:"rule"`
+	Severity             FindingSeverity `json:"severity"`
+	UniqueValues         int             `json:"unique_values"`
+	PreviousUniqueValues int             `json:"previous_unique_values,omitempty"`
+	Pattern              string          `json:"pattern,omitempty"`
+	Examples             []string        `json:"examples,omitempty"`
+	Message              string          `json:"message"`
+}
+
+type DropSuggestion struct {
+	ServiceName      string  `json:"service"`
+	MetricName       string  `json:"metric"`
+	LabelName        string  `json:"label"`
+	UniqueValues     int     `json:"unique_values"`
+	SeriesCount      int     `json:"series_count"`
+	SeriesAfter      int     `json:"series_after"`
+	SeriesReduction  int     `json:"series_reduction"`
+	ReductionPercent float64 `json:"reduction_percent"`
+	Message          string  `json:"message"`
+}
+
+type TSDBStat struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// TSDBOverview is the Prometheus TSDB status recorded with a snapshot in tsdb scan mode.
+type TSDBOverview struct {
+	SnapshotID                  int64      `json:"snapshot_id"`
+	HeadSeries                  int64      `json:"head_series"`
+	SeriesCountByMetricName     []TSDBStat `json:"series_count_by_metric_name"`
+	LabelValueCountByLabelName  []TSDBStat `json:"label_value_count_by_label_name"`
+	SeriesCountByLabelValuePair []TSDBStat `json:"series_count_by_label_value_pair"`
+	MemoryInBytesByLabelName    []TSDBStat `json:"memory_in_bytes_by_label_name"`
+}
+
+// ArchivedSnapshot is a snapshot with everything collected for it, as written to an export archive.
+// IDs are those of the exporting instance; importing assigns new ones.
+type ArchivedSnapshot struct {
+	Snapshot Snapshot           `json:"snapshot"`
+	Services []ArchivedService  `json:"services"`
+	Analyses []SnapshotAnalysis `json:"analyses,omitempty"` // analyses with this snapshot as the current one
+}
+
+type ArchivedService struct {
+	ServiceSnapshot
+	Metrics []ArchivedMetric `json:"metrics"`
+}
+
+type ArchivedMetric struct {
+	MetricSnapshot
+	Labels []LabelSnapshot `json:"labels,omitempty"`
+}
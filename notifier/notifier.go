@@ -0,0 +1,186 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/storage"
+)
+
+// Notifier evaluates cardinality thresholds after a scan and posts alerts to webhooks.
+type Notifier struct {
+	cfg        config.NotificationsConfig
+	snapshots  storage.SnapshotsRepo
+	diff       storage.DiffRepo
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+type Config struct {
+	Notifications config.NotificationsConfig
+	Snapshots     storage.SnapshotsRepo
+	Diff          storage.DiffRepo
+}
+
+func New(cfg Config) *Notifier {
+	return &Notifier{
+		cfg:        cfg.Notifications,
+		snapshots:  cfg.Snapshots,
+		diff:       cfg.Diff,
+		httpClient: &http.Client{Timeout: cfg.Notifications.Timeout},
+		logger:     slog.Default().With("component", "notifier"),
+	}
+}
+
+type Alert struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Service string `json:"service,omitempty"`
+	Metric  string `json:"metric,omitempty"`
+	Before  int64  `json:"before"`
+	After   int64  `json:"after"`
+	Link    string `json:"link,omitempty"`
+}
+
+type Payload struct {
+	Source             string    `json:"source,omitempty"`
+	Tenant             string    `json:"tenant,omitempty"`
+	SnapshotID         int64     `json:"snapshot_id"`
+	PreviousSnapshotID int64     `json:"previous_snapshot_id"`
+	Baseline           string    `json:"baseline,omitempty"` // set when compared with a pinned baseline
+	CollectedAt        time.Time `json:"collected_at"`
+	TotalSeriesBefore  int64     `json:"total_series_before"`
+	TotalSeriesAfter   int64     `json:"total_series_after"`
+	Alerts             []Alert   `json:"alerts"`
+	SnapshotURL        string    `json:"snapshot_url,omitempty"`
+	AnalysisURL        string    `json:"analysis_url,omitempty"`
+}
+
+// Notify compares a snapshot with its default baseline, or else the one before it, and sends a
+// notification if any threshold fires.
+func (n *Notifier) Notify(ctx context.Context, snapshotID int64) error {
+	if len(n.cfg.Webhooks) == 0 {
+		return nil
+	}
+
+	previous, err := n.snapshots.GetComparison(ctx, snapshotID)
+	if err != nil {
+		return fmt.Errorf("get comparison snapshot: %w", err)
+	}
+	if previous == nil {
+		return nil
+	}
+
+	diff, err := n.diff.Diff(ctx, previous.ID, snapshotID)
+	if err != nil {
+		return fmt.Errorf("diff snapshots: %w", err)
+	}
+	if diff == nil {
+		return nil
+	}
+
+	alerts := n.evaluate(diff)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	// The previous snapshot shares the current one's source and tenant.
+	payload := &Payload{
+		Source:             previous.Source,
+		Tenant:             previous.Tenant,
+		SnapshotID:         diff.ToSnapshotID,
+		PreviousSnapshotID: diff.FromSnapshotID,
+		CollectedAt:        diff.ToCollectedAt,
+		TotalSeriesBefore:  diff.FromTotalSeries,
+		TotalSeriesAfter:   diff.ToTotalSeries,
+		Alerts:             alerts,
+		SnapshotURL:        n.link("scans", fmt.Sprint(diff.ToSnapshotID)),
+	}
+	if previous.Baseline != nil {
+		payload.Baseline = previous.Baseline.Name
+	}
+	if n.cfg.BaseURL != "" {
+		payload.AnalysisURL = fmt.Sprintf("%s/#/analysis?current=%d&previous=%d",
+			strings.TrimSuffix(n.cfg.BaseURL, "/"), diff.ToSnapshotID, diff.FromSnapshotID)
+	}
+
+	n.logger.Info("cardinality thresholds exceeded", "snapshot_id", snapshotID, "alerts", len(alerts))
+
+	var errs []error
+	for _, wh := range n.cfg.Webhooks {
+		if err := n.send(ctx, wh, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) evaluate(diff *models.SnapshotDiff) []Alert {
+	t := n.cfg.Thresholds
+	var alerts []Alert
+
+	if t.TotalGrowthPercent > 0 && diff.FromTotalSeries > 0 && diff.SeriesDeltaPercent >= t.TotalGrowthPercent {
+		alerts = append(alerts, Alert{
+			Rule: "total_growth",
+			Message: fmt.Sprintf("total series grew by %.1f%% (%d → %d)",
+				diff.SeriesDeltaPercent, diff.FromTotalSeries, diff.ToTotalSeries),
+			Before: diff.FromTotalSeries,
+			After:  diff.ToTotalSeries,
+			Link:   n.link("scans", fmt.Sprint(diff.ToSnapshotID)),
+		})
+	}
+
+	for _, svc := range diff.Services {
+		if t.ServiceSeries > 0 && svc.FromSeries < t.ServiceSeries && svc.ToSeries >= t.ServiceSeries {
+			alerts = append(alerts, Alert{
+				Rule: "service_series",
+				Message: fmt.Sprintf("service %s exceeded %d series (%d → %d)",
+					svc.Name, t.ServiceSeries, svc.FromSeries, svc.ToSeries),
+				Service: svc.Name,
+				Before:  int64(svc.FromSeries),
+				After:   int64(svc.ToSeries),
+				Link:    n.link("scans", fmt.Sprint(diff.ToSnapshotID), "services", svc.Name),
+			})
+		}
+
+		if t.NewMetricSeries <= 0 {
+			continue
+		}
+		for _, metric := range svc.Metrics {
+			if metric.Status != models.DiffStatusAdded || metric.ToSeries < t.NewMetricSeries {
+				continue
+			}
+			alerts = append(alerts, Alert{
+				Rule: "new_metric_series",
+				Message: fmt.Sprintf("new metric %s in %s has %d series",
+					metric.Name, svc.Name, metric.ToSeries),
+				Service: svc.Name,
+				Metric:  metric.Name,
+				After:   int64(metric.ToSeries),
+				Link:    n.link("scans", fmt.Sprint(diff.ToSnapshotID), "services", svc.Name, "metrics", metric.Name),
+			})
+		}
+	}
+
+	return alerts
+}
+
+// link builds a deep link into the UI hash router, or returns "" when no base URL is configured.
+func (n *Notifier) link(segments ...string) string {
+	if n.cfg.BaseURL == "" {
+		return ""
+	}
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	return strings.TrimSuffix(n.cfg.BaseURL, "/") + "/#/" + strings.Join(escaped, "/")
+}
@@ -0,0 +1,194 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	thresholds := config.ThresholdsConfig{TotalGrowthPercent: 20, ServiceSeries: 1000, NewMetricSeries: 100}
+
+	tests := []struct {
+		name       string
+		thresholds config.ThresholdsConfig
+		diff       models.SnapshotDiff
+		wantRules  []string
+	}{
+		{
+			name:       "total growth",
+			thresholds: thresholds,
+			diff:       models.SnapshotDiff{FromTotalSeries: 1000, ToTotalSeries: 1250, SeriesDeltaPercent: 25},
+			wantRules:  []string{"total_growth"},
+		},
+		{
+			name:       "total growth below the threshold",
+			thresholds: thresholds,
+			diff:       models.SnapshotDiff{FromTotalSeries: 1000, ToTotalSeries: 1100, SeriesDeltaPercent: 10},
+		},
+		{
+			name:       "total growth from an empty scan",
+			thresholds: thresholds,
+			diff:       models.SnapshotDiff{FromTotalSeries: 0, ToTotalSeries: 500, SeriesDeltaPercent: 100},
+		},
+		{
+			name:       "service crosses the threshold",
+			thresholds: thresholds,
+			diff: models.SnapshotDiff{Services: []models.ServiceDiff{
+				{Name: "api", FromSeries: 900, ToSeries: 1000},
+				{Name: "worker", FromSeries: 1500, ToSeries: 2000}, // already above
+				{Name: "cron", FromSeries: 10, ToSeries: 999},
+			}},
+			wantRules: []string{"service_series"},
+		},
+		{
+			name:       "new metric",
+			thresholds: thresholds,
+			diff: models.SnapshotDiff{Services: []models.ServiceDiff{{
+				Name: "api",
+				Metrics: []models.MetricDiff{
+					{Name: "http_requests_total", Status: models.DiffStatusAdded, ToSeries: 100},
+					{Name: "up", Status: models.DiffStatusAdded, ToSeries: 99},
+					{Name: "http_duration_bucket", Status: models.DiffStatusChanged, FromSeries: 10, ToSeries: 500},
+				},
+			}}},
+			wantRules: []string{"new_metric_series"},
+		},
+		{
+			name:       "disabled rules",
+			thresholds: config.ThresholdsConfig{},
+			diff: models.SnapshotDiff{
+				FromTotalSeries: 1000, ToTotalSeries: 5000, SeriesDeltaPercent: 400,
+				Services: []models.ServiceDiff{{
+					Name: "api", FromSeries: 0, ToSeries: 5000,
+					Metrics: []models.MetricDiff{{Name: "up", Status: models.DiffStatusAdded, ToSeries: 5000}},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := New(Config{Notifications: config.NotificationsConfig{Thresholds: tt.thresholds}})
+			var rules []string
+			for _, a := range n.evaluate(&tt.diff) {
+				rules = append(rules, a.Rule)
+			}
+			if !slices.Equal(rules, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertFields(t *testing.T) {
+	n := New(Config{Notifications: config.NotificationsConfig{
+		BaseURL:    "https://wdt.example.com/",
+		Thresholds: config.ThresholdsConfig{ServiceSeries: 1000, NewMetricSeries: 100},
+	}})
+	alerts := n.evaluate(&models.SnapshotDiff{
+		ToSnapshotID: 7,
+		Services: []models.ServiceDiff{{
+			Name: "api", FromSeries: 900, ToSeries: 1200,
+			Metrics: []models.MetricDiff{{Name: "http_requests_total", Status: models.DiffStatusAdded, ToSeries: 300}},
+		}},
+	})
+	want := []Alert{
+		{
+			Rule:    "service_series",
+			Message: "service api exceeded 1000 series (900 → 1200)",
+			Service: "api",
+			Before:  900,
+			After:   1200,
+			Link:    "https://wdt.example.com/#/scans/7/services/api",
+		},
+		{
+			Rule:    "new_metric_series",
+			Message: "new metric http_requests_total in api has 300 series",
+			Service: "api",
+			Metric:  "http_requests_total",
+			After:   300,
+			Link:    "https://wdt.example.com/#/scans/7/services/api/metrics/http_requests_total",
+		},
+	}
+	if !slices.Equal(alerts, want) {
+		t.Errorf("alerts = %+v, want %+v", alerts, want)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var gotBody map[string]any
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		gotBody = nil
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n := New(Config{Notifications: config.NotificationsConfig{Timeout: 5 * time.Second}})
+	payload := &Payload{
+		Source:             "prod",
+		SnapshotID:         7,
+		PreviousSnapshotID: 3,
+		Baseline:           "before-upgrade",
+		TotalSeriesBefore:  1000,
+		TotalSeriesAfter:   1500,
+		Alerts: []Alert{
+			{Rule: "total_growth", Message: "total series grew by 50.0% (1000 → 1500)", Link: "https://wdt.example.com/#/scans/7"},
+			{Rule: "service_series", Message: "service api exceeded 1000 series (900 → 1200)"},
+		},
+		AnalysisURL: "https://wdt.example.com/#/analysis?current=7&previous=3",
+	}
+	ctx := context.Background()
+
+	t.Run("slack", func(t *testing.T) {
+		if err := n.send(ctx, config.WebhookConfig{URL: srv.URL, Type: "slack"}, payload); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		want := strings.Join([]string{
+			":rotating_light: *Cardinality alert* for prod scan 7 (total series 1000 → 1500)",
+			"Compared with baseline _before-upgrade_ (scan 3)",
+			"• <https://wdt.example.com/#/scans/7|total series grew by 50.0% (1000 → 1500)>",
+			"• service api exceeded 1000 series (900 → 1200)",
+			"<https://wdt.example.com/#/analysis?current=7&previous=3|Compare with baseline>",
+		}, "\n")
+		if len(gotBody) != 1 || gotBody["text"] != want {
+			t.Errorf("slack body = %q, want text %q", gotBody, want)
+		}
+	})
+
+	t.Run("generic", func(t *testing.T) {
+		if err := n.send(ctx, config.WebhookConfig{URL: srv.URL, Type: "generic"}, payload); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		if gotBody["snapshot_id"] != float64(7) || gotBody["baseline"] != "before-upgrade" {
+			t.Errorf("generic body = %v, want the payload", gotBody)
+		}
+		if alerts, _ := gotBody["alerts"].([]any); len(alerts) != 2 {
+			t.Errorf("generic body has %d alerts, want 2", len(alerts))
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusOK }()
+		err := n.send(ctx, config.WebhookConfig{URL: srv.URL}, payload)
+		if err == nil || !strings.Contains(err.Error(), "status 500") {
+			t.Errorf("err = %v, want the 500 status", err)
+		}
+	})
+}
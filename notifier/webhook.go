@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/illenko/whodidthis/config"
+	"github.com/illenko/whodidthis/models"
+)
+
+func (n *Notifier) send(ctx context.Context, wh config.WebhookConfig, payload *Payload) error {
+	var body any = payload
+	if wh.Type == "slack" {
+		body = slackMessage(payload)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// slackMessage renders the payload as a Slack-compatible incoming webhook message.
+func slackMessage(p *Payload) map[string]string {
+	var b strings.Builder
+	scope := models.Snapshot{Source: p.Source, Tenant: p.Tenant}
+	if name := scope.Scope(); name != "" {
+		fmt.Fprintf(&b, ":rotating_light: *Cardinality alert* for %s scan %d (total series %d → %d)\n",
+			name, p.SnapshotID, p.TotalSeriesBefore, p.TotalSeriesAfter)
+	} else {
+		fmt.Fprintf(&b, ":rotating_light: *Cardinality alert* for scan %d (total series %d → %d)\n",
+			p.SnapshotID, p.TotalSeriesBefore, p.TotalSeriesAfter)
+	}
+
+	if p.Baseline != "" {
+		fmt.Fprintf(&b, "Compared with baseline _%s_ (scan %d)\n", p.Baseline, p.PreviousSnapshotID)
+	}
+
+	for _, a := range p.Alerts {
+		if a.Link != "" {
+			fmt.Fprintf(&b, "• <%s|%s>\n", a.Link, a.Message)
+		} else {
+			fmt.Fprintf(&b, "• %s\n", a.Message)
+		}
+	}
+
+	if p.AnalysisURL != "" {
+		if p.Baseline != "" {
+			fmt.Fprintf(&b, "<%s|Compare with baseline>", p.AnalysisURL)
+		} else {
+			fmt.Fprintf(&b, "<%s|Compare with previous scan>", p.AnalysisURL)
+		}
+	}
+
+	return map[string]string{"text": b.String()}
+}
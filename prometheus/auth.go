@@ -0,0 +1,173 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+type OAuth2Config struct {
+	ClientID         string
+	ClientSecret     string
+	ClientSecretFile string
+	TokenURL         string
+	Scopes           []string
+	EndpointParams   map[string]string
+}
+
+// newTLSConfig builds the client TLS config: a custom CA bundle and/or a client certificate for mTLS.
+// It returns nil when nothing is configured, leaving Go's defaults in place.
+func newTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca file %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// newOAuth2Transport fetches tokens with the client credentials grant and refreshes them before expiry.
+// base carries token requests too, so the CA bundle and client certificate apply to the token URL.
+func newOAuth2Transport(cfg OAuth2Config, next, base http.RoundTripper) (http.RoundTripper, error) {
+	secret := cfg.ClientSecret
+	if cfg.ClientSecretFile != "" {
+		data, err := os.ReadFile(cfg.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("read oauth2 client secret file: %w", err)
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+
+	ccCfg := &clientcredentials.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: secret,
+		TokenURL:     cfg.TokenURL,
+		Scopes:       cfg.Scopes,
+	}
+	if len(cfg.EndpointParams) > 0 {
+		ccCfg.EndpointParams = make(map[string][]string, len(cfg.EndpointParams))
+		for k, v := range cfg.EndpointParams {
+			ccCfg.EndpointParams.Set(k, v)
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: base})
+	return &oauth2.Transport{
+		Source: ccCfg.TokenSource(ctx),
+		Base:   next,
+	}, nil
+}
+
+// bearerTokenTransport sets a static bearer token, or one read from a file.
+// The file is re-read whenever its modification time changes, so rotated tokens are picked up.
+type bearerTokenTransport struct {
+	transport http.RoundTripper
+	token     string
+	file      string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func (t *bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.currentToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.transport.RoundTrip(req)
+}
+
+func (t *bearerTokenTransport) currentToken() (string, error) {
+	if t.file == "" {
+		return t.token, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := os.Stat(t.file)
+	if err != nil {
+		return "", fmt.Errorf("stat bearer token file: %w", err)
+	}
+	if t.token != "" && info.ModTime().Equal(t.modTime) {
+		return t.token, nil
+	}
+
+	data, err := os.ReadFile(t.file)
+	if err != nil {
+		return "", fmt.Errorf("read bearer token file: %w", err)
+	}
+	t.token = strings.TrimSpace(string(data))
+	t.modTime = info.ModTime()
+	return t.token, nil
+}
+
+// headersTransport adds fixed headers to every request.
+type headersTransport struct {
+	transport http.RoundTripper
+	headers   map[string]string
+}
+
+func (t *headersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.transport.RoundTrip(req)
+}
+
+type basicAuthTransport struct {
+	transport http.RoundTripper
+	username  string
+	password  string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.transport.RoundTrip(req)
+}
@@ -0,0 +1,282 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const buildinfoBody = `{"status":"success","data":{"version":"3.1.0"}}`
+
+// headerRecorder serves buildinfo and remembers the headers and client certificate of the last request.
+type headerRecorder struct {
+	mu         sync.Mutex
+	header     http.Header
+	clientCert string
+}
+
+func (h *headerRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.header = r.Header.Clone()
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		h.clientCert = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	h.mu.Unlock()
+	w.Write([]byte(buildinfoBody))
+}
+
+func (h *headerRecorder) get(key string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.header.Get(key)
+}
+
+func (h *headerRecorder) cert() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clientCert
+}
+
+func fetchBuildinfo(t *testing.T, cfg Config) error {
+	t.Helper()
+	cfg.Retry = RetryConfig{MaxAttempts: 1}
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	_, err = c.get(context.Background(), "/api/v1/status/buildinfo", nil)
+	return err
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCert creates a self-signed client certificate and returns it with its PEM files.
+func newClientCert(t *testing.T, commonName string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := writeFile(t, "client.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyFile := writeFile(t, "client.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return cert, certFile, keyFile
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	clientCert, certFile, keyFile := newClientCert(t, "whodidthis")
+
+	rec := &headerRecorder{}
+	srv := httptest.NewUnstartedServer(rec)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	caFile := writeFile(t, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	tests := []struct {
+		name    string
+		tls     TLSConfig
+		wantErr bool
+	}{
+		{"unknown ca", TLSConfig{CertFile: certFile, KeyFile: keyFile}, true},
+		{"custom ca without client cert", TLSConfig{CAFile: caFile}, true},
+		{"custom ca and client cert", TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, false},
+		{"insecure skip verify", TLSConfig{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fetchBuildinfo(t, Config{URL: srv.URL, TLS: tt.tls})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("request error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := rec.cert(); !tt.wantErr && got != "whodidthis" {
+				t.Errorf("server saw client certificate %q, want whodidthis", got)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	notPEM := writeFile(t, "ca.crt", []byte("not a certificate"))
+
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{"missing ca file", TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.crt")}},
+		{"ca file without certificates", TLSConfig{CAFile: notPEM}},
+		{"cert without key", TLSConfig{CertFile: notPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTLSConfig(tt.cfg); err == nil {
+				t.Error("newTLSConfig succeeded, want an error")
+			}
+		})
+	}
+
+	if cfg, err := newTLSConfig(TLSConfig{}); cfg != nil || err != nil {
+		t.Errorf("empty TLSConfig = %v, %v; want nil, nil", cfg, err)
+	}
+}
+
+func TestBearerTokenFileReload(t *testing.T) {
+	rec := &headerRecorder{}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	tokenFile := writeFile(t, "token", []byte("first\n"))
+	c, err := NewClient(Config{URL: srv.URL, BearerTokenFile: tokenFile, Retry: RetryConfig{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	fetch := func() {
+		t.Helper()
+		if _, err := c.get(context.Background(), "/api/v1/status/buildinfo", nil); err != nil {
+			t.Fatalf("request: %v", err)
+		}
+	}
+
+	fetch()
+	if got := rec.get("Authorization"); got != "Bearer first" {
+		t.Fatalf("Authorization = %q, want Bearer first", got)
+	}
+
+	// The same modification time keeps the cached token.
+	info, err := os.Stat(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tokenFile, []byte("unseen"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tokenFile, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	fetch()
+	if got := rec.get("Authorization"); got != "Bearer first" {
+		t.Errorf("Authorization = %q after a write with the same mtime, want the cached token", got)
+	}
+
+	// A new modification time reloads the file.
+	if err := os.WriteFile(tokenFile, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(tokenFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	fetch()
+	if got := rec.get("Authorization"); got != "Bearer second" {
+		t.Errorf("Authorization = %q after rotation, want Bearer second", got)
+	}
+}
+
+func TestOAuth2TokenInjection(t *testing.T) {
+	var tokenRequests int
+	var mu sync.Mutex
+	var gotClientID, gotSecret, gotScope, gotAudience string
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		tokenRequests++
+		gotClientID, gotSecret, _ = r.BasicAuth()
+		gotScope = r.Form.Get("scope")
+		gotAudience = r.Form.Get("audience")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"oauth-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(tokenSrv.Close)
+
+	rec := &headerRecorder{}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	secretFile := writeFile(t, "secret", []byte("s3cret\n"))
+	c, err := NewClient(Config{
+		URL: srv.URL,
+		OAuth2: &OAuth2Config{
+			ClientID:         "whodidthis",
+			ClientSecretFile: secretFile,
+			TokenURL:         tokenSrv.URL,
+			Scopes:           []string{"metrics.read"},
+			EndpointParams:   map[string]string{"audience": "prometheus"},
+		},
+		Headers: map[string]string{"X-Custom": "yes"},
+		Retry:   RetryConfig{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	for range 2 {
+		if _, err := c.get(context.Background(), "/api/v1/status/buildinfo", nil); err != nil {
+			t.Fatalf("request: %v", err)
+		}
+	}
+
+	if got := rec.get("Authorization"); got != "Bearer oauth-token" {
+		t.Errorf("Authorization = %q, want Bearer oauth-token", got)
+	}
+	if got := rec.get("X-Custom"); got != "yes" {
+		t.Errorf("X-Custom = %q, want yes", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, want 1 for a token that has not expired", tokenRequests)
+	}
+	if gotClientID != "whodidthis" || gotSecret != "s3cret" {
+		t.Errorf("client credentials = %q/%q, want whodidthis/s3cret from the secret file", gotClientID, gotSecret)
+	}
+	if gotScope != "metrics.read" || gotAudience != "prometheus" {
+		t.Errorf("scope = %q, audience = %q", gotScope, gotAudience)
+	}
+}
@@ -0,0 +1,99 @@
+package prometheus
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/illenko/whodidthis/telemetry"
+)
+
+// ErrCircuitOpen is returned without contacting the backend while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("prometheus circuit breaker open: backend is failing")
+
+// CircuitBreakerConfig opens the breaker after FailureThreshold consecutive failed requests
+// (after retries). While open, requests fail fast with ErrCircuitOpen; after Cooldown a single
+// probe request decides whether it closes again. A zero threshold disables the breaker.
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a request may be sent. Once the cooldown has passed, only one
+// probe is let through until its outcome is recorded.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed request. Requests that were
+// neither a success nor a backend failure (e.g. cancelled) only release the probe slot.
+func (b *circuitBreaker) record(success, failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.probing
+	b.probing = false
+
+	switch {
+	case success:
+		if b.failures >= b.threshold {
+			slog.Info("prometheus circuit breaker closed", "url", b.name)
+		}
+		b.failures = 0
+	case failure:
+		b.failures++
+		if b.failures == b.threshold || (wasProbe && b.failures > b.threshold) {
+			b.openUntil = time.Now().Add(b.cooldown)
+			telemetry.PrometheusCircuitOpen.Inc()
+			slog.Warn("prometheus circuit breaker open", "url", b.name, "failures", b.failures, "cooldown", b.cooldown)
+		}
+	}
+}
+
+// breakerTransport guards the retrying transport, so a request counts as one failure
+// only after its retries are exhausted.
+type breakerTransport struct {
+	transport http.RoundTripper
+	breaker   *circuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	switch {
+	case err != nil:
+		t.breaker.record(false, req.Context().Err() == nil)
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		t.breaker.record(false, true)
+	default:
+		// Client errors such as a rejected query still prove the backend is up.
+		t.breaker.record(true, false)
+	}
+	return resp, err
+}
@@ -0,0 +1,106 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{name: "test", threshold: 2, cooldown: 20 * time.Millisecond}
+
+	b.record(false, true)
+	if !b.allow() {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+	b.record(true, false)
+	b.record(false, true)
+	if !b.allow() {
+		t.Fatal("a success did not reset the failure count")
+	}
+
+	b.record(false, true)
+	if b.allow() {
+		t.Fatal("breaker closed after reaching the threshold")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("no probe allowed after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("a second request passed while the probe is running")
+	}
+
+	// A failed probe reopens the breaker for another cooldown.
+	b.record(false, true)
+	if b.allow() {
+		t.Fatal("breaker allowed a request right after a failed probe")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("no probe allowed after the second cooldown")
+	}
+	// A cancelled probe only frees the probe slot.
+	b.record(false, false)
+	if !b.allow() {
+		t.Fatal("no new probe allowed after a cancelled probe")
+	}
+	b.record(true, false)
+	for range 3 {
+		if !b.allow() {
+			t.Fatal("breaker still open after a successful probe")
+		}
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	var calls int
+	outcome := status(503, "")
+	bt := &breakerTransport{
+		transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			return outcome()
+		}),
+		breaker: &circuitBreaker{name: "test", threshold: 2, cooldown: time.Hour},
+	}
+	do := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://prometheus/api/v1/query", nil)
+		_, err := bt.RoundTrip(req)
+		return err
+	}
+
+	// Client errors prove the backend is up and do not count.
+	outcome = status(400, "")
+	for range 3 {
+		do(context.Background())
+	}
+	if bt.breaker.failures != 0 {
+		t.Errorf("failures = %d after client errors, want 0", bt.breaker.failures)
+	}
+
+	// Cancelled requests do not count either.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	outcome = func() (*http.Response, error) { return nil, context.Canceled }
+	do(ctx)
+	if bt.breaker.failures != 0 {
+		t.Errorf("failures = %d after a cancelled request, want 0", bt.breaker.failures)
+	}
+
+	outcome = status(503, "")
+	do(context.Background())
+	outcome = func() (*http.Response, error) { return nil, errConnReset }
+	do(context.Background())
+
+	calls = 0
+	if err := do(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 0 {
+		t.Errorf("open breaker contacted the backend %d times", calls)
+	}
+}
@@ -0,0 +1,167 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BudgetConfig bounds the load a client puts on the backend's query path. Zero values are unlimited.
+type BudgetConfig struct {
+	MaxQPS              float64 // requests per second, retries included
+	MaxConcurrentSeries int     // label lookups (/api/v1/series requests for Prometheus) in flight
+}
+
+// ErrSeriesBudgetExhausted is returned by GetLabelsForMetric once the scan's SeriesBudget is spent.
+var ErrSeriesBudgetExhausted = errors.New("series budget for this scan exhausted")
+
+// SeriesBudget caps how many series a scan fetches label details for. It is shared by every
+// request whose context carries it (see WithSeriesBudget). The first metric that does not fit
+// exhausts it, so a scan stops fetching labels at that point rather than skipping ahead to
+// smaller metrics.
+type SeriesBudget struct {
+	remaining atomic.Int64
+	exhausted atomic.Bool
+}
+
+func NewSeriesBudget(max int64) *SeriesBudget {
+	b := &SeriesBudget{}
+	b.remaining.Store(max)
+	return b
+}
+
+// Remaining returns how many series may still be fetched.
+func (b *SeriesBudget) Remaining() int64 {
+	if b.exhausted.Load() {
+		return 0
+	}
+	return b.remaining.Load()
+}
+
+// Exhausted reports whether a lookup was refused because it did not fit.
+func (b *SeriesBudget) Exhausted() bool {
+	return b.exhausted.Load()
+}
+
+// reserve spends n series if they fit in the budget. Otherwise nothing is spent and the budget
+// is exhausted.
+func (b *SeriesBudget) reserve(n int) bool {
+	for !b.exhausted.Load() {
+		remaining := b.remaining.Load()
+		if int64(n) > remaining {
+			b.exhausted.Store(true)
+			return false
+		}
+		if b.remaining.CompareAndSwap(remaining, remaining-int64(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+type seriesBudgetKey struct{}
+
+// WithSeriesBudget returns a context whose series lookups are charged to budget.
+func WithSeriesBudget(ctx context.Context, budget *SeriesBudget) context.Context {
+	return context.WithValue(ctx, seriesBudgetKey{}, budget)
+}
+
+func seriesBudgetFromContext(ctx context.Context) *SeriesBudget {
+	budget, _ := ctx.Value(seriesBudgetKey{}).(*SeriesBudget)
+	return budget
+}
+
+// budgetedClient applies BudgetConfig.MaxConcurrentSeries and the scan's SeriesBudget to the
+// label lookups of any flavor. NewMetricsClient wraps every client it returns in one.
+type budgetedClient struct {
+	MetricsClient
+	seriesSem chan struct{} // limits concurrent label lookups, nil when unlimited
+}
+
+func newBudgetedClient(client MetricsClient, cfg BudgetConfig) *budgetedClient {
+	c := &budgetedClient{MetricsClient: client}
+	if cfg.MaxConcurrentSeries > 0 {
+		c.seriesSem = make(chan struct{}, cfg.MaxConcurrentSeries)
+	}
+	return c
+}
+
+// GetLabelsForMetric charges the metric's series to the budget before anything is fetched, so
+// a metric that does not fit costs no request.
+func (c *budgetedClient) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName string, metric MetricInfo, sampleLimit int) ([]LabelInfo, error) {
+	if budget := seriesBudgetFromContext(ctx); budget != nil && !budget.reserve(metric.SeriesCount) {
+		return nil, fmt.Errorf("get labels for %s: %w", metric.Name, ErrSeriesBudgetExhausted)
+	}
+
+	release, err := c.acquireSeries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return c.MetricsClient.GetLabelsForMetric(ctx, serviceLabel, serviceName, metric, sampleLimit)
+}
+
+// acquireSeries takes a label lookup slot, if the client limits them, and returns its release func.
+func (c *budgetedClient) acquireSeries(ctx context.Context) (func(), error) {
+	if c.seriesSem == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.seriesSem <- struct{}{}:
+		return func() { <-c.seriesSem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// rateLimitTransport spaces requests to at most MaxQPS. It sits below the retry
+// transport, so every attempt is paced.
+type rateLimitTransport struct {
+	transport http.RoundTripper
+	limiter   *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// rateLimiter hands out one request slot per interval, without bursts.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
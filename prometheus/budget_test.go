@@ -0,0 +1,144 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSeriesBudget(t *testing.T) {
+	b := NewSeriesBudget(100)
+
+	if !b.reserve(60) || b.Remaining() != 40 {
+		t.Fatalf("after reserving 60: remaining %d, want 40", b.Remaining())
+	}
+	if b.reserve(50) {
+		t.Fatal("reserved 50 series with 40 left")
+	}
+	if !b.Exhausted() || b.Remaining() != 0 {
+		t.Errorf("after a refused reservation: exhausted %v, remaining %d", b.Exhausted(), b.Remaining())
+	}
+	if b.reserve(10) {
+		t.Error("an exhausted budget reserved a smaller metric")
+	}
+}
+
+// labelsClient is a MetricsClient whose label lookups block on release and record how many
+// run at once.
+type labelsClient struct {
+	MetricsClient
+	release  chan struct{}
+	calls    atomic.Int32
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (c *labelsClient) GetLabelsForMetric(ctx context.Context, _, _ string, _ MetricInfo, _ int) ([]LabelInfo, error) {
+	c.calls.Add(1)
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	if c.release != nil {
+		<-c.release
+	}
+	return []LabelInfo{{Name: "path", UniqueValues: 1}}, nil
+}
+
+func TestBudgetedClientSeriesBudget(t *testing.T) {
+	inner := &labelsClient{}
+	client := newBudgetedClient(inner, BudgetConfig{})
+	ctx := WithSeriesBudget(context.Background(), NewSeriesBudget(100))
+
+	tests := []struct {
+		series  int
+		wantErr bool
+	}{
+		{60, false},
+		{50, true}, // does not fit in the 40 left
+		{10, true}, // would fit, but the budget is exhausted
+	}
+	for _, tt := range tests {
+		_, err := client.GetLabelsForMetric(ctx, "job", "api", MetricInfo{Name: "m", SeriesCount: tt.series}, 5)
+		if gotErr := errors.Is(err, ErrSeriesBudgetExhausted); gotErr != tt.wantErr {
+			t.Errorf("metric with %d series: err = %v, want exhausted %v", tt.series, err, tt.wantErr)
+		}
+	}
+	if calls := inner.calls.Load(); calls != 1 {
+		t.Errorf("%d lookups reached the backend, want only the one that fit", calls)
+	}
+
+	// Without a budget in the context, lookups are not charged.
+	if _, err := client.GetLabelsForMetric(context.Background(), "job", "api", MetricInfo{Name: "m", SeriesCount: 1000}, 5); err != nil {
+		t.Errorf("lookup without a budget: %v", err)
+	}
+}
+
+func TestBudgetedClientMaxConcurrentSeries(t *testing.T) {
+	inner := &labelsClient{release: make(chan struct{})}
+	client := newBudgetedClient(inner, BudgetConfig{MaxConcurrentSeries: 2})
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.GetLabelsForMetric(context.Background(), "job", "api", MetricInfo{Name: "m"}, 5)
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for inner.inFlight.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // give a third lookup the chance to slip through
+	close(inner.release)
+	wg.Wait()
+
+	if peak := inner.peak.Load(); peak != 2 {
+		t.Errorf("peak concurrent lookups = %d, want 2", peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	full := newBudgetedClient(inner, BudgetConfig{MaxConcurrentSeries: 1})
+	full.seriesSem <- struct{}{}
+	if _, err := full.GetLabelsForMetric(ctx, "job", "api", MetricInfo{Name: "m"}, 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("waiting for a slot with a cancelled context: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestNewMetricsClientAppliesBudgetToEveryFlavor(t *testing.T) {
+	for _, flavor := range []string{FlavorPrometheus, FlavorVictoriaMetrics, FlavorMimir} {
+		t.Run(flavor, func(t *testing.T) {
+			srv := newTestServer(t, map[string]string{})
+			client, _, err := NewMetricsClient(context.Background(), Config{
+				URL:    srv.URL,
+				Flavor: flavor,
+				Retry:  RetryConfig{MaxAttempts: 1},
+			})
+			if err != nil {
+				t.Fatalf("NewMetricsClient: %v", err)
+			}
+
+			ctx := WithSeriesBudget(context.Background(), NewSeriesBudget(10))
+			_, err = client.GetLabelsForMetric(ctx, "job", "api", MetricInfo{Name: "http_requests_total", SeriesCount: 11}, 5)
+			if !errors.Is(err, ErrSeriesBudgetExhausted) {
+				t.Errorf("err = %v, want ErrSeriesBudgetExhausted", err)
+			}
+			srv.mu.Lock()
+			requests := len(srv.queries)
+			srv.mu.Unlock()
+			if requests != 0 {
+				t.Errorf("%d paths were requested, want none for a metric over the budget", requests)
+			}
+		})
+	}
+}
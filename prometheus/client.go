@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"sort"
 	"time"
 
@@ -17,18 +18,35 @@ type MetricsClient interface {
 	HealthCheck(ctx context.Context) error
 	DiscoverServices(ctx context.Context, serviceLabel string) ([]ServiceInfo, error)
 	GetMetricsForService(ctx context.Context, serviceLabel, serviceName string) ([]MetricInfo, error)
-	GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName, metricName string, sampleLimit int) ([]LabelInfo, error)
+	GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName string, metric MetricInfo, sampleLimit int) ([]LabelInfo, error)
+	GetSeriesCountWithoutLabel(ctx context.Context, serviceLabel, serviceName, metricName, labelName string) (int, error)
+	GetTSDBStatus(ctx context.Context, limit int) (*TSDBStatus, error)
 }
 
 type Client struct {
-	api v1.API
+	api    v1.API
+	client api.Client
 }
 
 type Config struct {
 	URL      string
 	Username string
 	Password string
-	Timeout  time.Duration
+	// BearerToken or BearerTokenFile set an Authorization: Bearer header; the file is re-read when it changes.
+	BearerToken     string
+	BearerTokenFile string
+	OAuth2          *OAuth2Config
+	TLS             TLSConfig
+	Headers         map[string]string
+	Timeout         time.Duration
+	// Flavor selects the backend implementation, see NewMetricsClient.
+	Flavor string
+	// Tenants enables multi-tenant requests: each request carries X-Scope-OrgID from its
+	// context (see WithTenant), or the first tenant when the context has none.
+	Tenants        []string
+	Retry          RetryConfig
+	CircuitBreaker CircuitBreakerConfig
+	Budget         BudgetConfig
 }
 
 func NewClient(cfg Config) (*Client, error) {
@@ -37,20 +55,83 @@ func NewClient(cfg Config) (*Client, error) {
 		timeout = 30 * time.Second
 	}
 
+	tlsCfg, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout: 5 * time.Second,
 		}).DialContext,
+		TLSClientConfig:       tlsCfg,
 		TLSHandshakeTimeout:   5 * time.Second,
 		ResponseHeaderTimeout: timeout,
 		MaxIdleConns:          10,
 		IdleConnTimeout:       90 * time.Second,
 	}
 
-	var rt http.RoundTripper = transport
-	if cfg.Username != "" && cfg.Password != "" {
+	retry := cfg.Retry
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = 3
+	}
+	if retry.InitialBackoff <= 0 {
+		retry.InitialBackoff = 500 * time.Millisecond
+	}
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = 10 * time.Second
+	}
+
+	var rt http.RoundTripper = &instrumentedTransport{transport: transport}
+	if cfg.Budget.MaxQPS > 0 {
+		rt = &rateLimitTransport{
+			transport: rt,
+			limiter:   &rateLimiter{interval: time.Duration(float64(time.Second) / cfg.Budget.MaxQPS)},
+		}
+	}
+	rt = &retryTransport{transport: rt, cfg: retry}
+	if cfg.CircuitBreaker.FailureThreshold > 0 {
+		cooldown := cfg.CircuitBreaker.Cooldown
+		if cooldown <= 0 {
+			cooldown = 30 * time.Second
+		}
+		rt = &breakerTransport{
+			transport: rt,
+			breaker: &circuitBreaker{
+				name:      cfg.URL,
+				threshold: cfg.CircuitBreaker.FailureThreshold,
+				cooldown:  cooldown,
+			},
+		}
+	}
+	if len(cfg.Tenants) > 0 {
+		rt = &tenantTransport{
+			transport:     rt,
+			defaultTenant: cfg.Tenants[0],
+		}
+	}
+	if len(cfg.Headers) > 0 {
+		rt = &headersTransport{
+			transport: rt,
+			headers:   cfg.Headers,
+		}
+	}
+
+	switch {
+	case cfg.OAuth2 != nil:
+		rt, err = newOAuth2Transport(*cfg.OAuth2, rt, transport)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.BearerToken != "" || cfg.BearerTokenFile != "":
+		rt = &bearerTokenTransport{
+			transport: rt,
+			token:     cfg.BearerToken,
+			file:      cfg.BearerTokenFile,
+		}
+	case cfg.Username != "" && cfg.Password != "":
 		rt = &basicAuthTransport{
-			transport: transport,
+			transport: rt,
 			username:  cfg.Username,
 			password:  cfg.Password,
 		}
@@ -67,10 +148,39 @@ func NewClient(cfg Config) (*Client, error) {
 	}
 
 	return &Client{
-		api: v1.NewAPI(client),
+		api:    v1.NewAPI(client),
+		client: client,
 	}, nil
 }
 
+// get issues a GET request relative to the API base URL and returns the body of a 2xx response.
+// It is used for endpoints the v1 API does not cover.
+func (c *Client) get(ctx context.Context, path string, params url.Values) ([]byte, error) {
+	u := c.client.URL(path, nil)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, body, err := c.client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned %s: %s", path, resp.Status, truncate(string(body), 200))
+	}
+	return body, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
 func (c *Client) HealthCheck(ctx context.Context) error {
 	_, err := c.api.Runtimeinfo(ctx)
 	if err != nil {
@@ -159,12 +269,12 @@ type LabelInfo struct {
 	SampleValues []string
 }
 
-func (c *Client) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName, metricName string, sampleLimit int) ([]LabelInfo, error) {
-	selector := fmt.Sprintf(`%s{%s="%s"}`, metricName, serviceLabel, serviceName)
+func (c *Client) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName string, metric MetricInfo, sampleLimit int) ([]LabelInfo, error) {
+	selector := fmt.Sprintf(`%s{%s="%s"}`, metric.Name, serviceLabel, serviceName)
 
 	series, _, err := c.api.Series(ctx, []string{selector}, time.Time{}, time.Time{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get labels for %s: %w", metricName, err)
+		return nil, fmt.Errorf("failed to get labels for %s: %w", metric.Name, err)
 	}
 
 	labelValues := make(map[string]map[string]struct{})
@@ -213,14 +323,66 @@ func (c *Client) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceNa
 	return labels, nil
 }
 
-type basicAuthTransport struct {
-	transport http.RoundTripper
-	username  string
-	password  string
+// GetSeriesCountWithoutLabel returns how many series the metric would have if labelName were dropped.
+func (c *Client) GetSeriesCountWithoutLabel(ctx context.Context, serviceLabel, serviceName, metricName, labelName string) (int, error) {
+	query := fmt.Sprintf(`count(count without (%s) (%s{%s="%s"}))`, labelName, metricName, serviceLabel, serviceName)
+
+	result, _, err := c.api.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to count series of %s without %s: %w", metricName, labelName, err)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return 0, fmt.Errorf("unexpected result type: %T", result)
+	}
+	if len(vector) == 0 {
+		return 0, nil
+	}
+
+	return int(vector[0].Value), nil
 }
 
-func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req = req.Clone(req.Context())
-	req.SetBasicAuth(t.username, t.password)
-	return t.transport.RoundTrip(req)
+type Stat struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// TSDBStatus is the head block cardinality overview from /api/v1/status/tsdb.
+type TSDBStatus struct {
+	HeadSeries                  int64
+	SeriesCountByMetricName     []Stat
+	LabelValueCountByLabelName  []Stat
+	SeriesCountByLabelValuePair []Stat
+	MemoryInBytesByLabelName    []Stat
+}
+
+// GetTSDBStatus reads the TSDB status in a single request. limit caps each statistic list;
+// servers older than Prometheus 2.49 ignore it and return their top 10.
+func (c *Client) GetTSDBStatus(ctx context.Context, limit int) (*TSDBStatus, error) {
+	var opts []v1.Option
+	if limit > 0 {
+		opts = append(opts, v1.WithLimit(uint64(limit)))
+	}
+
+	result, err := c.api.TSDB(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tsdb status: %w", err)
+	}
+
+	return &TSDBStatus{
+		HeadSeries:                  int64(result.HeadStats.NumSeries),
+		SeriesCountByMetricName:     convertStats(result.SeriesCountByMetricName),
+		LabelValueCountByLabelName:  convertStats(result.LabelValueCountByLabelName),
+		SeriesCountByLabelValuePair: convertStats(result.SeriesCountByLabelValuePair),
+		MemoryInBytesByLabelName:    convertStats(result.MemoryInBytesByLabelName),
+	}, nil
+}
+
+func convertStats(stats []v1.Stat) []Stat {
+	out := make([]Stat, 0, len(stats))
+	for _, s := range stats {
+		out = append(out, Stat{Name: s.Name, Value: int64(s.Value)})
+	}
+	return out
 }
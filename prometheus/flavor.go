@@ -0,0 +1,77 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	FlavorPrometheus      = "prometheus"
+	FlavorVictoriaMetrics = "victoriametrics"
+	FlavorMimir           = "mimir"
+	FlavorAuto            = "auto"
+)
+
+// NewMetricsClient creates the MetricsClient for cfg.Flavor and returns the flavor it chose.
+// FlavorAuto probes the server with DetectFlavor; if probing fails, the generic Prometheus
+// client is returned together with the detection error. Every flavor is wrapped to enforce
+// cfg.Budget on its label lookups.
+func NewMetricsClient(ctx context.Context, cfg Config) (MetricsClient, string, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	flavor := cfg.Flavor
+	if flavor == FlavorAuto {
+		flavor, err = client.DetectFlavor(ctx)
+		if err != nil {
+			return newBudgetedClient(client, cfg.Budget), FlavorPrometheus, fmt.Errorf("detect flavor: %w", err)
+		}
+	}
+
+	switch flavor {
+	case "", FlavorPrometheus:
+		return newBudgetedClient(client, cfg.Budget), FlavorPrometheus, nil
+	case FlavorVictoriaMetrics:
+		return newBudgetedClient(&VictoriaMetricsClient{Client: client}, cfg.Budget), flavor, nil
+	case FlavorMimir:
+		return newBudgetedClient(&MimirClient{Client: client}, cfg.Budget), flavor, nil
+	default:
+		return nil, "", fmt.Errorf("unknown flavor %q", flavor)
+	}
+}
+
+// DetectFlavor identifies the backend: Mimir reports its application name in buildinfo,
+// and only VictoriaMetrics includes totalSeries in its TSDB status.
+func (c *Client) DetectFlavor(ctx context.Context) (string, error) {
+	if body, err := c.get(ctx, "/api/v1/status/buildinfo", nil); err == nil {
+		var info struct {
+			Data struct {
+				Application string `json:"application"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(body, &info) == nil && strings.Contains(strings.ToLower(info.Data.Application), "mimir") {
+			return FlavorMimir, nil
+		}
+	}
+
+	body, err := c.get(ctx, "/api/v1/status/tsdb", url.Values{"topN": {"1"}, "limit": {"1"}})
+	if err != nil {
+		return "", err
+	}
+
+	var status struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return "", fmt.Errorf("decode tsdb status: %w", err)
+	}
+	if _, ok := status.Data["totalSeries"]; ok {
+		return FlavorVictoriaMetrics, nil
+	}
+	return FlavorPrometheus, nil
+}
@@ -0,0 +1,163 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// testServer serves fixed JSON bodies by path and 404 for anything else. It records the query of
+// every request.
+type testServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	queries map[string][]url.Values
+}
+
+func newTestServer(t *testing.T, bodies map[string]string) *testServer {
+	t.Helper()
+	s := &testServer{queries: make(map[string][]url.Values)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.mu.Lock()
+		s.queries[r.URL.Path] = append(s.queries[r.URL.Path], r.Form)
+		s.mu.Unlock()
+
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) lastQuery(path string) url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queries[path]
+	if len(q) == 0 {
+		return nil
+	}
+	return q[len(q)-1]
+}
+
+func newTestClient(t *testing.T, rawURL string) *Client {
+	t.Helper()
+	c, err := NewClient(Config{URL: rawURL, Retry: RetryConfig{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func TestDetectFlavor(t *testing.T) {
+	tests := []struct {
+		name   string
+		bodies map[string]string
+		want   string
+	}{
+		{
+			name: "mimir buildinfo",
+			bodies: map[string]string{
+				"/api/v1/status/buildinfo": `{"status":"success","data":{"application":"Grafana Mimir","version":"2.14.0"}}`,
+			},
+			want: FlavorMimir,
+		},
+		{
+			name: "victoriametrics tsdb status",
+			bodies: map[string]string{
+				"/api/v1/status/tsdb": `{"status":"success","data":{"totalSeries":42,"seriesCountByMetricName":[]}}`,
+			},
+			want: FlavorVictoriaMetrics,
+		},
+		{
+			name: "prometheus",
+			bodies: map[string]string{
+				"/api/v1/status/buildinfo": `{"status":"success","data":{"version":"3.1.0","revision":"abc"}}`,
+				"/api/v1/status/tsdb":      `{"status":"success","data":{"headStats":{"numSeries":42},"seriesCountByMetricName":[]}}`,
+			},
+			want: FlavorPrometheus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.bodies)
+
+			got, err := newTestClient(t, srv.URL).DetectFlavor(context.Background())
+			if err != nil {
+				t.Fatalf("DetectFlavor: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFlavor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMetricsClientAuto(t *testing.T) {
+	tests := []struct {
+		name       string
+		bodies     map[string]string
+		wantFlavor string
+		wantErr    bool
+	}{
+		{
+			name:       "victoriametrics",
+			bodies:     map[string]string{"/api/v1/status/tsdb": `{"status":"success","data":{"totalSeries":1}}`},
+			wantFlavor: FlavorVictoriaMetrics,
+		},
+		{
+			name:       "mimir",
+			bodies:     map[string]string{"/api/v1/status/buildinfo": `{"status":"success","data":{"application":"Mimir"}}`},
+			wantFlavor: FlavorMimir,
+		},
+		{
+			name:       "probe fails falls back to prometheus",
+			bodies:     map[string]string{},
+			wantFlavor: FlavorPrometheus,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.bodies)
+
+			client, flavor, err := NewMetricsClient(context.Background(), Config{
+				URL:    srv.URL,
+				Flavor: FlavorAuto,
+				Retry:  RetryConfig{MaxAttempts: 1},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMetricsClient error = %v, want error %v", err, tt.wantErr)
+			}
+			if flavor != tt.wantFlavor {
+				t.Errorf("flavor = %q, want %q", flavor, tt.wantFlavor)
+			}
+
+			budgeted, ok := client.(*budgetedClient)
+			if !ok {
+				t.Fatalf("client is %T, want it wrapped in the budget", client)
+			}
+			switch tt.wantFlavor {
+			case FlavorVictoriaMetrics:
+				_, ok = budgeted.MetricsClient.(*VictoriaMetricsClient)
+			case FlavorMimir:
+				_, ok = budgeted.MetricsClient.(*MimirClient)
+			default:
+				_, ok = budgeted.MetricsClient.(*Client)
+			}
+			if !ok {
+				t.Errorf("client is %T for flavor %q", budgeted.MetricsClient, tt.wantFlavor)
+			}
+		})
+	}
+}
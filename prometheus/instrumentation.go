@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/illenko/whodidthis/telemetry"
+)
+
+// instrumentedTransport records latency, errors and response sizes per Prometheus API method.
+// Latency covers the full response body, since large /series responses dominate scan time.
+type instrumentedTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := apiMethod(req)
+	start := time.Now()
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		telemetry.PrometheusRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		telemetry.PrometheusRequestErrors.WithLabelValues(method).Inc()
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		telemetry.PrometheusRequestErrors.WithLabelValues(method).Inc()
+	}
+
+	resp.Body = &countingBody{
+		ReadCloser: resp.Body,
+		onClose: func(size int64) {
+			telemetry.PrometheusRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+			telemetry.PrometheusResponseSize.WithLabelValues(method).Observe(float64(size))
+		},
+	}
+	return resp, nil
+}
+
+// apiMethod maps /api/v1/query to "query", /api/v1/series to "series", and so on.
+func apiMethod(req *http.Request) string {
+	return path.Base(req.URL.Path)
+}
+
+type countingBody struct {
+	io.ReadCloser
+	size    int64
+	once    sync.Once
+	onClose func(size int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.onClose(b.size) })
+	return b.ReadCloser.Close()
+}
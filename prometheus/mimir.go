@@ -0,0 +1,169 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// mimirMaxLimit is the largest limit Mimir's cardinality API accepts.
+const mimirMaxLimit = 500
+
+// MimirClient reads label cardinality from Mimir's cardinality API
+// (/api/v1/cardinality/label_names and /label_values) instead of fetching every series.
+// The API must be enabled with -querier.cardinality-analysis-enabled.
+type MimirClient struct {
+	*Client
+}
+
+type mimirLabelNames struct {
+	LabelValuesCountTotal int64 `json:"label_values_count_total"`
+	Cardinality           []struct {
+		LabelName        string `json:"label_name"`
+		LabelValuesCount int64  `json:"label_values_count"`
+	} `json:"cardinality"`
+}
+
+type mimirLabelValues struct {
+	SeriesCountTotal int64 `json:"series_count_total"`
+	Labels           []struct {
+		LabelName        string `json:"label_name"`
+		LabelValuesCount int64  `json:"label_values_count"`
+		SeriesCount      int64  `json:"series_count"`
+		Cardinality      []struct {
+			LabelValue  string `json:"label_value"`
+			SeriesCount int64  `json:"series_count"`
+		} `json:"cardinality"`
+	} `json:"labels"`
+}
+
+func (c *MimirClient) labelNames(ctx context.Context, selector string, limit int) (*mimirLabelNames, error) {
+	params := url.Values{"limit": {strconv.Itoa(clampMimirLimit(limit))}}
+	if selector != "" {
+		params.Set("selector", selector)
+	}
+
+	body, err := c.get(ctx, "/api/v1/cardinality/label_names", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp mimirLabelNames
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode label names cardinality: %w", err)
+	}
+	return &resp, nil
+}
+
+func (c *MimirClient) labelValues(ctx context.Context, selector string, labelNames []string, limit int) (*mimirLabelValues, error) {
+	params := url.Values{
+		"label_names[]": labelNames,
+		"limit":         {strconv.Itoa(clampMimirLimit(limit))},
+	}
+	if selector != "" {
+		params.Set("selector", selector)
+	}
+
+	body, err := c.get(ctx, "/api/v1/cardinality/label_values", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp mimirLabelValues
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode label values cardinality: %w", err)
+	}
+	return &resp, nil
+}
+
+// GetLabelsForMetric lists the metric's label names, then fetches value counts and
+// the top sampleLimit values of each label in a second request.
+func (c *MimirClient) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName string, metric MetricInfo, sampleLimit int) ([]LabelInfo, error) {
+	selector := fmt.Sprintf(`%s{%s="%s"}`, metric.Name, serviceLabel, serviceName)
+
+	names, err := c.labelNames(ctx, selector, mimirMaxLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labels for %s: %w", metric.Name, err)
+	}
+
+	var labelNames []string
+	for _, l := range names.Cardinality {
+		if l.LabelName == "__name__" || l.LabelName == serviceLabel {
+			continue
+		}
+		labelNames = append(labelNames, l.LabelName)
+	}
+	if len(labelNames) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.labelValues(ctx, selector, labelNames, sampleLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label values for %s: %w", metric.Name, err)
+	}
+
+	var labels []LabelInfo
+	for _, l := range values.Labels {
+		samples := make([]string, 0, len(l.Cardinality))
+		for _, v := range l.Cardinality {
+			samples = append(samples, v.LabelValue)
+		}
+		sort.Strings(samples)
+
+		labels = append(labels, LabelInfo{
+			Name:         l.LabelName,
+			UniqueValues: int(l.LabelValuesCount),
+			SampleValues: samples,
+		})
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].UniqueValues > labels[j].UniqueValues
+	})
+
+	return labels, nil
+}
+
+// GetTSDBStatus assembles a TSDB status from the cardinality API, since Mimir has no
+// /api/v1/status/tsdb. Label/value pairs and memory usage are not available.
+func (c *MimirClient) GetTSDBStatus(ctx context.Context, limit int) (*TSDBStatus, error) {
+	names, err := c.labelNames(ctx, "", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tsdb status: %w", err)
+	}
+
+	metrics, err := c.labelValues(ctx, "", []string{"__name__"}, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tsdb status: %w", err)
+	}
+
+	status := &TSDBStatus{
+		HeadSeries: metrics.SeriesCountTotal,
+	}
+	for _, l := range names.Cardinality {
+		status.LabelValueCountByLabelName = append(status.LabelValueCountByLabelName, Stat{
+			Name:  l.LabelName,
+			Value: l.LabelValuesCount,
+		})
+	}
+	for _, l := range metrics.Labels {
+		for _, v := range l.Cardinality {
+			status.SeriesCountByMetricName = append(status.SeriesCountByMetricName, Stat{
+				Name:  v.LabelValue,
+				Value: v.SeriesCount,
+			})
+		}
+	}
+
+	return status, nil
+}
+
+func clampMimirLimit(limit int) int {
+	if limit <= 0 || limit > mimirMaxLimit {
+		return mimirMaxLimit
+	}
+	return limit
+}
@@ -0,0 +1,102 @@
+package prometheus
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMimirGetLabelsForMetric(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v1/cardinality/label_names": `{
+			"label_values_count_total": 58,
+			"label_names_count": 4,
+			"cardinality": [
+				{"label_name": "__name__", "label_values_count": 1},
+				{"label_name": "job", "label_values_count": 1},
+				{"label_name": "path", "label_values_count": 50},
+				{"label_name": "method", "label_values_count": 6}
+			]
+		}`,
+		"/api/v1/cardinality/label_values": `{
+			"series_count_total": 300,
+			"labels": [
+				{"label_name": "method", "label_values_count": 6, "series_count": 300,
+					"cardinality": [{"label_value": "POST", "series_count": 100}, {"label_value": "GET", "series_count": 200}]},
+				{"label_name": "path", "label_values_count": 50, "series_count": 300,
+					"cardinality": [{"label_value": "/b", "series_count": 20}, {"label_value": "/a", "series_count": 30}]}
+			]
+		}`,
+	})
+	client := &MimirClient{Client: newTestClient(t, srv.URL)}
+
+	labels, err := client.GetLabelsForMetric(context.Background(), "job", "api", MetricInfo{Name: "http_requests_total"}, 2)
+	if err != nil {
+		t.Fatalf("GetLabelsForMetric: %v", err)
+	}
+
+	want := []LabelInfo{
+		{Name: "path", UniqueValues: 50, SampleValues: []string{"/a", "/b"}},
+		{Name: "method", UniqueValues: 6, SampleValues: []string{"GET", "POST"}},
+	}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %+v, want %+v", labels, want)
+	}
+
+	q := srv.lastQuery("/api/v1/cardinality/label_values")
+	if got := q["label_names[]"]; !reflect.DeepEqual(got, []string{"path", "method"}) {
+		t.Errorf("label_names[] = %v, want the labels without __name__ and the service label", got)
+	}
+	if got := q.Get("selector"); got != `http_requests_total{job="api"}` {
+		t.Errorf("selector = %q", got)
+	}
+	if got := q.Get("limit"); got != "2" {
+		t.Errorf("limit = %q, want the sample limit", got)
+	}
+}
+
+func TestMimirGetTSDBStatus(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v1/cardinality/label_names": `{
+			"cardinality": [{"label_name": "instance", "label_values_count": 12}, {"label_name": "job", "label_values_count": 3}]
+		}`,
+		"/api/v1/cardinality/label_values": `{
+			"series_count_total": 1500,
+			"labels": [{"label_name": "__name__", "label_values_count": 2, "series_count": 1500,
+				"cardinality": [{"label_value": "up", "series_count": 1000}, {"label_value": "go_goroutines", "series_count": 500}]}]
+		}`,
+	})
+	client := &MimirClient{Client: newTestClient(t, srv.URL)}
+
+	status, err := client.GetTSDBStatus(context.Background(), 5000)
+	if err != nil {
+		t.Fatalf("GetTSDBStatus: %v", err)
+	}
+
+	want := &TSDBStatus{
+		HeadSeries:                 1500,
+		SeriesCountByMetricName:    []Stat{{Name: "up", Value: 1000}, {Name: "go_goroutines", Value: 500}},
+		LabelValueCountByLabelName: []Stat{{Name: "instance", Value: 12}, {Name: "job", Value: 3}},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("status = %+v, want %+v", status, want)
+	}
+	if got := srv.lastQuery("/api/v1/cardinality/label_names").Get("limit"); got != "500" {
+		t.Errorf("limit = %q, want it clamped to 500", got)
+	}
+}
+
+func TestClampMimirLimit(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{0, 500},
+		{-1, 500},
+		{10, 10},
+		{500, 500},
+		{501, 500},
+	}
+	for _, tt := range tests {
+		if got := clampMimirLimit(tt.in); got != tt.want {
+			t.Errorf("clampMimirLimit(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/illenko/whodidthis/telemetry"
+)
+
+// RetryConfig controls retries of transient failures. MaxAttempts counts the first
+// request, so 1 disables retries.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// maxRetryAfter caps how long a Retry-After header can pause a request.
+const maxRetryAfter = time.Minute
+
+// tooManySamples is the error Prometheus returns when a query exceeds --query.max-samples.
+// It depends on the head block at query time, so it often passes on a later attempt.
+const tooManySamples = "query processing would load too many samples"
+
+// retryTransport retries 5xx and 429 responses, transport errors such as connection
+// resets, and too-many-samples query errors, with jittered exponential backoff.
+type retryTransport struct {
+	transport http.RoundTripper
+	cfg       RetryConfig
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A body that cannot be replayed can only be sent once.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return t.transport.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := t.transport.RoundTrip(r)
+		retry, wait := t.shouldRetry(ctx, resp, err)
+		if !retry || attempt >= t.cfg.MaxAttempts {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		wait = max(wait, t.backoff(attempt))
+		telemetry.PrometheusRequestRetries.WithLabelValues(apiMethod(req)).Inc()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether the outcome is transient, and the minimum wait the server asked for.
+func (t *retryTransport) shouldRetry(ctx context.Context, resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		return ctx.Err() == nil, 0
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true, retryAfter(resp.Header.Get("Retry-After"))
+	case resp.StatusCode >= 500:
+		return true, retryAfter(resp.Header.Get("Retry-After"))
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		// Read the error body to tell a sample limit from a bad query, then hand it back intact.
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return readErr == nil && strings.Contains(string(body), tooManySamples), 0
+	}
+	return false, 0
+}
+
+// backoff returns the delay before the given retry: the initial backoff doubled per attempt,
+// capped at the maximum, with up to half of it randomized so parallel workers spread out.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.cfg.InitialBackoff
+	for i := 1; i < attempt && d < t.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, t.cfg.MaxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(header); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		d = time.Until(at)
+	}
+	return max(0, min(d, maxRetryAfter))
+}
@@ -0,0 +1,232 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func response(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// scripted returns the outcomes in order, one per request, and counts the requests.
+func scripted(calls *int, outcomes ...func() (*http.Response, error)) http.RoundTripper {
+	return roundTripFunc(func(*http.Request) (*http.Response, error) {
+		i := min(*calls, len(outcomes)-1)
+		*calls++
+		return outcomes[i]()
+	})
+}
+
+func status(code int, body string) func() (*http.Response, error) {
+	return func() (*http.Response, error) { return response(code, body), nil }
+}
+
+var errConnReset = errors.New("connection reset by peer")
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		outcomes   []func() (*http.Response, error)
+		wantCalls  int
+		wantStatus int
+		wantErr    bool
+	}{
+		{
+			name:       "success",
+			outcomes:   []func() (*http.Response, error){status(200, "ok")},
+			wantCalls:  1,
+			wantStatus: 200,
+		},
+		{
+			name:       "5xx then success",
+			outcomes:   []func() (*http.Response, error){status(503, ""), status(502, ""), status(200, "ok")},
+			wantCalls:  3,
+			wantStatus: 200,
+		},
+		{
+			name:       "429 then success",
+			outcomes:   []func() (*http.Response, error){status(429, ""), status(200, "ok")},
+			wantCalls:  2,
+			wantStatus: 200,
+		},
+		{
+			name: "connection reset then success",
+			outcomes: []func() (*http.Response, error){
+				func() (*http.Response, error) { return nil, errConnReset },
+				status(200, "ok"),
+			},
+			wantCalls:  2,
+			wantStatus: 200,
+		},
+		{
+			name:       "too many samples then success",
+			outcomes:   []func() (*http.Response, error){status(422, `{"error":"`+tooManySamples+`"}`), status(200, "ok")},
+			wantCalls:  2,
+			wantStatus: 200,
+		},
+		{
+			name:       "bad query is not retried",
+			outcomes:   []func() (*http.Response, error){status(422, `{"error":"parse error"}`)},
+			wantCalls:  1,
+			wantStatus: 422,
+		},
+		{
+			name:       "client error is not retried",
+			outcomes:   []func() (*http.Response, error){status(400, "")},
+			wantCalls:  1,
+			wantStatus: 400,
+		},
+		{
+			name:       "attempts exhausted returns the last response",
+			outcomes:   []func() (*http.Response, error){status(500, "")},
+			wantCalls:  3,
+			wantStatus: 500,
+		},
+		{
+			name:      "attempts exhausted returns the last error",
+			outcomes:  []func() (*http.Response, error){func() (*http.Response, error) { return nil, errConnReset }},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			rt := &retryTransport{
+				transport: scripted(&calls, tt.outcomes...),
+				cfg:       RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond},
+			}
+			req, _ := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+
+			resp, err := rt.RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoundTrip error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if resp != nil && resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRetryTransportKeepsRejectedQueryBody(t *testing.T) {
+	var calls int
+	rt := &retryTransport{
+		transport: scripted(&calls, status(422, `{"error":"parse error"}`)),
+		cfg:       RetryConfig{MaxAttempts: 3},
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"error":"parse error"}` {
+		t.Errorf("body = %q, want the original error body", body)
+	}
+}
+
+func TestRetryTransportReplaysBody(t *testing.T) {
+	var bodies []string
+	calls := 0
+	rt := &retryTransport{
+		transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			b, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+			calls++
+			if calls == 1 {
+				return response(503, ""), nil
+			}
+			return response(200, "ok"), nil
+		}),
+		cfg: RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	}
+	req, _ := http.NewRequest(http.MethodPost, "http://prometheus/api/v1/query", strings.NewReader("query=up"))
+
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != "query=up" || bodies[1] != "query=up" {
+		t.Errorf("bodies = %q, want the form sent twice", bodies)
+	}
+}
+
+func TestRetryTransportStopsOnCancel(t *testing.T) {
+	var calls int
+	rt := &retryTransport{
+		transport: scripted(&calls, status(503, "")),
+		cfg:       RetryConfig{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://prometheus/api/v1/query", nil)
+
+	if _, err := rt.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RoundTrip error = %v, want context.DeadlineExceeded", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rt := &retryTransport{cfg: RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}}
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 300 * time.Millisecond},
+		{10, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := rt.backoff(tt.attempt); d < tt.base/2 || d > tt.base {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.attempt, d, tt.base/2, tt.base)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-3", 0},
+		{"3600", maxRetryAfter},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	date := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date); got < 28*time.Second || got > 30*time.Second {
+		t.Errorf("retryAfter(%q) = %v, want about 30s", date, got)
+	}
+}
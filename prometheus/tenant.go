@@ -0,0 +1,43 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+)
+
+// TenantHeader is the header Mimir, Cortex and Loki use to select a tenant.
+const TenantHeader = "X-Scope-OrgID"
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose Prometheus requests are sent as the given tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or an empty string.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenantTransport sets X-Scope-OrgID from the request context,
+// falling back to defaultTenant for requests without one (e.g. health checks).
+type tenantTransport struct {
+	transport     http.RoundTripper
+	defaultTenant string
+}
+
+func (t *tenantTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tenant := TenantFromContext(req.Context())
+	if tenant == "" {
+		tenant = t.defaultTenant
+	}
+	if tenant == "" {
+		return t.transport.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(TenantHeader, tenant)
+	return t.transport.RoundTrip(req)
+}
@@ -0,0 +1,138 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// vmTopN bounds the per-statistic lists requested from VictoriaMetrics.
+// It must cover every label of a metric and every service.
+const vmTopN = 10000
+
+// VictoriaMetricsClient reads cardinality from VictoriaMetrics' TSDB stats
+// (/api/v1/status/tsdb with match[] and focusLabel) instead of fetching every series.
+type VictoriaMetricsClient struct {
+	*Client
+}
+
+type vmTSDBStatus struct {
+	TotalSeries                  int64  `json:"totalSeries"`
+	SeriesCountByMetricName      []Stat `json:"seriesCountByMetricName"`
+	SeriesCountByFocusLabelValue []Stat `json:"seriesCountByFocusLabelValue"`
+	SeriesCountByLabelValuePair  []Stat `json:"seriesCountByLabelValuePair"`
+	LabelValueCountByLabelName   []Stat `json:"labelValueCountByLabelName"`
+}
+
+func (c *VictoriaMetricsClient) tsdbStatus(ctx context.Context, params url.Values) (*vmTSDBStatus, error) {
+	body, err := c.get(ctx, "/api/v1/status/tsdb", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Status string       `json:"status"`
+		Data   vmTSDBStatus `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode tsdb status: %w", err)
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("tsdb status returned %q", resp.Status)
+	}
+	return &resp.Data, nil
+}
+
+func (c *VictoriaMetricsClient) DiscoverServices(ctx context.Context, serviceLabel string) ([]ServiceInfo, error) {
+	status, err := c.tsdbStatus(ctx, url.Values{
+		"focusLabel": {serviceLabel},
+		"topN":       {strconv.Itoa(vmTopN)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+
+	var services []ServiceInfo
+	for _, s := range status.SeriesCountByFocusLabelValue {
+		if s.Name == "" {
+			continue
+		}
+		services = append(services, ServiceInfo{
+			Name:        s.Name,
+			SeriesCount: int(s.Value),
+		})
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].SeriesCount > services[j].SeriesCount
+	})
+
+	return services, nil
+}
+
+// GetLabelsForMetric takes unique value counts from labelValueCountByLabelName and sample values
+// from the top label/value pairs of the matching series, in one request.
+func (c *VictoriaMetricsClient) GetLabelsForMetric(ctx context.Context, serviceLabel, serviceName string, metric MetricInfo, sampleLimit int) ([]LabelInfo, error) {
+	status, err := c.tsdbStatus(ctx, url.Values{
+		"match[]": {fmt.Sprintf(`%s{%s="%s"}`, metric.Name, serviceLabel, serviceName)},
+		"topN":    {strconv.Itoa(vmTopN)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labels for %s: %w", metric.Name, err)
+	}
+
+	samples := make(map[string][]string)
+	for _, pair := range status.SeriesCountByLabelValuePair {
+		name, value, ok := strings.Cut(pair.Name, "=")
+		if !ok || len(samples[name]) >= sampleLimit {
+			continue
+		}
+		samples[name] = append(samples[name], value)
+	}
+
+	var labels []LabelInfo
+	for _, s := range status.LabelValueCountByLabelName {
+		if s.Name == "__name__" || s.Name == serviceLabel {
+			continue
+		}
+		values := samples[s.Name]
+		sort.Strings(values)
+
+		labels = append(labels, LabelInfo{
+			Name:         s.Name,
+			UniqueValues: int(s.Value),
+			SampleValues: values,
+		})
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].UniqueValues > labels[j].UniqueValues
+	})
+
+	return labels, nil
+}
+
+// GetTSDBStatus reads VictoriaMetrics' TSDB stats, which take topN instead of limit
+// and do not report memory usage.
+func (c *VictoriaMetricsClient) GetTSDBStatus(ctx context.Context, limit int) (*TSDBStatus, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("topN", strconv.Itoa(limit))
+	}
+
+	status, err := c.tsdbStatus(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tsdb status: %w", err)
+	}
+
+	return &TSDBStatus{
+		HeadSeries:                  status.TotalSeries,
+		SeriesCountByMetricName:     status.SeriesCountByMetricName,
+		LabelValueCountByLabelName:  status.LabelValueCountByLabelName,
+		SeriesCountByLabelValuePair: status.SeriesCountByLabelValuePair,
+	}, nil
+}
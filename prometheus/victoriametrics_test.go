@@ -0,0 +1,92 @@
+package prometheus
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestVictoriaMetricsDiscoverServices(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v1/status/tsdb": `{"status":"success","data":{
+			"totalSeries": 350,
+			"seriesCountByFocusLabelValue": [
+				{"name": "api", "value": 100},
+				{"name": "", "value": 50},
+				{"name": "worker", "value": 200}
+			]
+		}}`,
+	})
+	client := &VictoriaMetricsClient{Client: newTestClient(t, srv.URL)}
+
+	services, err := client.DiscoverServices(context.Background(), "job")
+	if err != nil {
+		t.Fatalf("DiscoverServices: %v", err)
+	}
+
+	want := []ServiceInfo{{Name: "worker", SeriesCount: 200}, {Name: "api", SeriesCount: 100}}
+	if !reflect.DeepEqual(services, want) {
+		t.Errorf("services = %+v, want %+v", services, want)
+	}
+	if got := srv.lastQuery("/api/v1/status/tsdb").Get("focusLabel"); got != "job" {
+		t.Errorf("focusLabel = %q, want job", got)
+	}
+}
+
+func TestVictoriaMetricsGetLabelsForMetric(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v1/status/tsdb": `{"status":"success","data":{
+			"totalSeries": 300,
+			"labelValueCountByLabelName": [
+				{"name": "__name__", "value": 1},
+				{"name": "job", "value": 1},
+				{"name": "method", "value": 6},
+				{"name": "path", "value": 50}
+			],
+			"seriesCountByLabelValuePair": [
+				{"name": "path=/c", "value": 40},
+				{"name": "method=GET", "value": 30},
+				{"name": "path=/a", "value": 20},
+				{"name": "path=/b", "value": 10},
+				{"name": "malformed", "value": 5}
+			]
+		}}`,
+	})
+	client := &VictoriaMetricsClient{Client: newTestClient(t, srv.URL)}
+
+	labels, err := client.GetLabelsForMetric(context.Background(), "job", "api", MetricInfo{Name: "http_requests_total"}, 2)
+	if err != nil {
+		t.Fatalf("GetLabelsForMetric: %v", err)
+	}
+
+	want := []LabelInfo{
+		{Name: "path", UniqueValues: 50, SampleValues: []string{"/a", "/c"}},
+		{Name: "method", UniqueValues: 6, SampleValues: []string{"GET"}},
+	}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %+v, want %+v", labels, want)
+	}
+	if got := srv.lastQuery("/api/v1/status/tsdb").Get("match[]"); got != `http_requests_total{job="api"}` {
+		t.Errorf("match[] = %q", got)
+	}
+}
+
+func TestVictoriaMetricsTSDBStatusErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"error status", `{"status":"error","error":"unsupported"}`},
+		{"invalid json", `{"status":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, map[string]string{"/api/v1/status/tsdb": tt.body})
+			client := &VictoriaMetricsClient{Client: newTestClient(t, srv.URL)}
+
+			if _, err := client.GetTSDBStatus(context.Background(), 10); err == nil {
+				t.Error("GetTSDBStatus succeeded, want an error")
+			}
+		})
+	}
+}
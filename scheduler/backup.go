@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Backup is the schedule of database backups into Dir, of which the newest Keep are kept.
+type Backup struct {
+	Schedule cron.Schedule
+	Dir      string
+	Keep     int
+}
+
+// runBackups writes a backup each time the backup schedule comes due, until the scheduler stops.
+// Backups are consistent while scans write, so they don't wait for a running scan.
+func (s *Scheduler) runBackups(ctx context.Context) {
+	s.logger.Info("scheduling database backups", "dir", s.backup.Dir, "keep", s.backup.Keep,
+		"next_backup_at", s.backup.Schedule.Next(time.Now()))
+
+	for {
+		timer := time.NewTimer(time.Until(s.backup.Schedule.Next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+			start := time.Now()
+			path, err := s.db.BackupToDir(ctx, s.backup.Dir, s.backup.Keep)
+			if err != nil {
+				s.logger.Error("backup failed", "dir", s.backup.Dir, "error", err)
+				continue
+			}
+			s.logger.Info("backup completed", "file", path, "duration", time.Since(start))
+		}
+	}
+}
@@ -2,28 +2,37 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/illenko/whodidthis/collector"
+	"github.com/illenko/whodidthis/detector"
+	"github.com/illenko/whodidthis/models"
+	"github.com/illenko/whodidthis/notifier"
 	"github.com/illenko/whodidthis/storage"
+	"github.com/robfig/cron/v3"
 )
 
 type Scheduler struct {
-	collector *collector.Collector
-	db        *storage.DB
-	interval  time.Duration
-	retention time.Duration
-	stopCh    chan struct{}
-	stopOnce  sync.Once
-	status    *ScanStatus
-	mu        sync.RWMutex
-	scanIDSeq atomic.Int64
-	logger    *slog.Logger
-	parentCtx context.Context // set by Start, used for triggered scans
-	scanWg    sync.WaitGroup  // tracks async triggered scans
+	collector  *collector.Collector
+	detector   *detector.Detector
+	notifier   *notifier.Notifier
+	db         *storage.DB
+	runs       storage.ScanRunsRepo
+	profiles   []Profile
+	retention  []storage.RetentionTier
+	backup     *Backup
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+	status     *ScanStatus
+	mu         sync.RWMutex
+	logger     *slog.Logger
+	parentCtx  context.Context         // set by Start, used for triggered scans
+	cancelScan context.CancelCauseFunc // cancels the running scan, nil when idle
+	scanWg     sync.WaitGroup          // tracks async triggered scans
 }
 
 type ScanProgress struct {
@@ -39,30 +48,53 @@ type ScanStatus struct {
 	LastScanAt    time.Time     `json:"last_scan_at,omitempty"`
 	LastDuration  string        `json:"last_duration,omitempty"`
 	LastError     string        `json:"last_error,omitempty"`
+	Profile       string        `json:"profile,omitempty"` // profile of the running or last scan
 	NextScanAt    time.Time     `json:"next_scan_at,omitempty"`
+	NextProfile   string        `json:"next_profile,omitempty"`
 	TotalServices int           `json:"total_services,omitempty"`
 	TotalSeries   int64         `json:"total_series,omitempty"`
 }
 
+// Profile is a scan profile and the cron schedule it runs on.
+type Profile struct {
+	Schedule cron.Schedule
+	Scan     *collector.Profile // nil runs the collector's default profile
+}
+
+func (p Profile) name() string {
+	if p.Scan == nil {
+		return "default"
+	}
+	return p.Scan.Name
+}
+
 type Config struct {
-	Interval  time.Duration
-	Retention time.Duration
+	Profiles  []Profile               // the first profile also runs at startup and for manual scans
+	Retention []storage.RetentionTier // ordered by MaxAge
+	Backup    *Backup                 // optional, writes scheduled backups of DB
 	DB        *storage.DB
+	Runs      storage.ScanRunsRepo // persists each scan's status and failures; its ID is the scan ID
+	Detector  *detector.Detector   // optional, runs label detection after each scan
+	Notifier  *notifier.Notifier   // optional, sends threshold notifications after each scan
 }
 
 func New(collector *collector.Collector, cfg Config) *Scheduler {
-	if cfg.Interval == 0 {
-		cfg.Interval = 24 * time.Hour
+	if len(cfg.Profiles) == 0 {
+		cfg.Profiles = []Profile{{Schedule: cron.Every(24 * time.Hour)}}
 	}
-	if cfg.Retention == 0 {
-		cfg.Retention = 90 * 24 * time.Hour // 90 days default
+	if len(cfg.Retention) == 0 {
+		cfg.Retention = []storage.RetentionTier{{MaxAge: 90 * 24 * time.Hour}} // 90 days default
 	}
 
 	return &Scheduler{
 		collector: collector,
+		detector:  cfg.Detector,
+		notifier:  cfg.Notifier,
 		db:        cfg.DB,
-		interval:  cfg.Interval,
+		runs:      cfg.Runs,
+		profiles:  cfg.Profiles,
 		retention: cfg.Retention,
+		backup:    cfg.Backup,
 		stopCh:    make(chan struct{}),
 		status:    &ScanStatus{},
 		logger:    slog.Default(),
@@ -71,30 +103,59 @@ func New(collector *collector.Collector, cfg Config) *Scheduler {
 
 func (s *Scheduler) Start(ctx context.Context) {
 	s.parentCtx = ctx
-	s.logger.Info("starting scheduler", "interval", s.interval)
+	for _, p := range s.profiles {
+		s.logger.Info("scheduling scan profile", "profile", p.name(), "next_scan_at", p.Schedule.Next(time.Now()))
+	}
 
-	// Run initial scan
-	s.executeScan(ctx)
+	if n, err := s.runs.FailInterrupted(ctx); err != nil {
+		s.logger.Error("failed to close interrupted scan runs", "error", err)
+	} else if n > 0 {
+		s.logger.Warn("marked scan runs interrupted by a restart as failed", "runs", n)
+	}
 
-	ticker := time.NewTicker(s.interval)
-	defer ticker.Stop()
+	if s.backup != nil && s.db != nil {
+		go s.runBackups(ctx)
+	}
+
+	// Run initial scan
+	s.executeScan(ctx, s.profiles[0])
+
+	// Scheduled scans run one at a time on this loop, so a profile that comes due during
+	// another scheduled scan starts right after it, once. One that comes due during a
+	// manual scan is skipped until its next scheduled time.
+	next := make([]time.Time, len(s.profiles))
+	for i, p := range s.profiles {
+		next[i] = p.Schedule.Next(time.Now())
+	}
 
 	for {
+		due := 0
+		for i := range next {
+			if next[i].Before(next[due]) {
+				due = i
+			}
+		}
+
 		s.mu.Lock()
-		s.status.NextScanAt = time.Now().Add(s.interval)
+		s.status.NextScanAt = next[due]
+		s.status.NextProfile = s.profiles[due].name()
 		s.mu.Unlock()
 
+		timer := time.NewTimer(time.Until(next[due]))
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			s.scanWg.Wait()
 			s.logger.Info("scheduler stopped")
 			return
 		case <-s.stopCh:
+			timer.Stop()
 			s.scanWg.Wait()
 			s.logger.Info("scheduler stopped")
 			return
-		case <-ticker.C:
-			s.executeScan(ctx)
+		case <-timer.C:
+			s.executeScan(ctx, s.profiles[due])
+			next[due] = s.profiles[due].Schedule.Next(time.Now())
 		}
 	}
 }
@@ -105,30 +166,53 @@ func (s *Scheduler) Stop() {
 	})
 }
 
-func (s *Scheduler) TriggerScan() error {
+// TriggerScan starts a scan of the named profile in the background, or of the first profile
+// when profileName is empty. A non-nil filter rescans only the matching services into
+// targeted snapshots.
+func (s *Scheduler) TriggerScan(profileName string, filter *collector.ServiceFilter) error {
+	profile := s.profiles[0]
+	if profileName != "" {
+		i := slices.IndexFunc(s.profiles, func(p Profile) bool { return p.name() == profileName })
+		if i < 0 {
+			return ErrUnknownProfile
+		}
+		profile = s.profiles[i]
+	}
+
 	s.mu.Lock()
 	if s.status.Running {
 		s.mu.Unlock()
 		return ErrScanAlreadyRunning
 	}
-	s.status.Running = true
-	s.status.LastError = ""
-	s.status.Progress = &ScanProgress{Phase: "starting"}
 	ctx := s.parentCtx
-	s.mu.Unlock()
-
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	ctx = s.beginScan(ctx, profile)
+	s.mu.Unlock()
 
 	s.scanWg.Add(1)
 	go func() {
 		defer s.scanWg.Done()
-		s.doScan(ctx)
+		s.doScan(ctx, profile, filter)
 	}()
 	return nil
 }
 
+// CancelScan stops the running scan. Snapshots it was writing are kept as cancelled,
+// without the services that had not finished.
+func (s *Scheduler) CancelScan() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.status.Running || s.cancelScan == nil {
+		return ErrNoScanRunning
+	}
+	s.cancelScan(ErrScanCancelled)
+	s.status.Progress = &ScanProgress{Phase: "cancelling"}
+	return nil
+}
+
 func (s *Scheduler) GetStatus() ScanStatus {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -136,33 +220,63 @@ func (s *Scheduler) GetStatus() ScanStatus {
 }
 
 // executeScan acquires the running lock and runs a scan synchronously.
-func (s *Scheduler) executeScan(ctx context.Context) {
+func (s *Scheduler) executeScan(ctx context.Context, profile Profile) {
 	s.mu.Lock()
 	if s.status.Running {
 		s.mu.Unlock()
+		s.logger.Warn("skipping scheduled scan: another scan is running", "profile", profile.name())
 		return
 	}
+	ctx = s.beginScan(ctx, profile)
+	s.mu.Unlock()
+
+	s.doScan(ctx, profile, nil)
+}
+
+// beginScan marks a scan as running and returns its context, which CancelScan cancels.
+// Caller must hold s.mu.
+func (s *Scheduler) beginScan(parent context.Context, profile Profile) context.Context {
+	ctx, cancel := context.WithCancelCause(parent)
+	s.cancelScan = cancel
 	s.status.Running = true
 	s.status.LastError = ""
+	s.status.Profile = profile.name()
 	s.status.Progress = &ScanProgress{Phase: "starting"}
-	s.mu.Unlock()
-
-	s.doScan(ctx)
+	return ctx
 }
 
-// doScan runs the actual scan. Caller must have already set status.Running = true.
-func (s *Scheduler) doScan(ctx context.Context) {
-	scanID := s.scanIDSeq.Add(1)
+// doScan runs the actual scan. Caller must have already called beginScan.
+func (s *Scheduler) doScan(ctx context.Context, profile Profile, filter *collector.ServiceFilter) {
 	start := time.Now()
 
-	logger := s.logger.With("scan_id", scanID)
-	logger.Info("starting scan")
-
 	var result *collector.CollectResult
 	var scanErr error
 
+	run, err := s.runs.Create(ctx, start)
+	if err != nil {
+		scanErr = fmt.Errorf("create scan run: %w", err)
+		s.logger.Error("failed to start scan", "error", err)
+		s.mu.Lock()
+		s.endScan()
+		s.status.Running = false
+		s.status.Progress = nil
+		s.status.LastError = scanErr.Error()
+		s.mu.Unlock()
+		return
+	}
+	scanID := run.ID
+
+	logger := s.logger.With("scan_id", scanID, "profile", profile.name())
+	if filter != nil {
+		logger = logger.With("filter", filter.String())
+	}
+	logger.Info("starting scan")
+
 	defer func() {
+		s.finishRun(ctx, scanID, result, scanErr)
+
 		s.mu.Lock()
+		s.endScan()
 		s.status.Running = false
 		s.status.Progress = nil
 		s.status.LastScanAt = start
@@ -187,23 +301,92 @@ func (s *Scheduler) doScan(ctx context.Context) {
 		}
 	}
 
-	result, scanErr = s.collector.Collect(ctx, scanID, progress)
+	result, scanErr = s.collector.Collect(ctx, scanID, profile.Scan, filter, progress)
+	if ctx.Err() != nil {
+		scanErr = context.Cause(ctx)
+		logger.Warn("scan cancelled", "reason", scanErr, "duration", time.Since(start))
+		return
+	}
 	if scanErr != nil {
 		logger.Error("collection failed", "error", scanErr)
 		return
 	}
 
 	logger.Info("scan complete",
+		"snapshots", len(result.SnapshotIDs),
 		"services", result.TotalServices,
 		"series", result.TotalSeries,
+		"service_errors", result.ServiceErrors,
+		"metric_errors", result.MetricErrors,
+		"target_errors", result.TargetErrors,
 		"duration", time.Since(start),
 	)
 
+	for _, snapshotID := range result.SnapshotIDs {
+		s.runDetection(ctx, scanID, snapshotID)
+		// Thresholds compare whole snapshots, which a targeted scan does not produce.
+		if filter == nil {
+			s.runNotifications(ctx, scanID, snapshotID)
+		}
+	}
 	s.runCleanup(ctx, scanID)
 }
 
+// endScan releases the scan context. Caller must hold s.mu.
+func (s *Scheduler) endScan() {
+	if s.cancelScan != nil {
+		s.cancelScan(nil)
+		s.cancelScan = nil
+	}
+}
+
+// finishRun records the outcome of the scan run. It runs even when ctx was cancelled.
+func (s *Scheduler) finishRun(ctx context.Context, scanID int64, result *collector.CollectResult, scanErr error) {
+	status := models.ScanRunStatusCompleted
+	var errText string
+	switch {
+	case ctx.Err() != nil:
+		status = models.ScanRunStatusCancelled
+		errText = context.Cause(ctx).Error()
+	case scanErr != nil:
+		status = models.ScanRunStatusFailed
+		errText = scanErr.Error()
+	case result.Partial():
+		status = models.ScanRunStatusPartial
+		if result.Err != nil {
+			errText = result.Err.Error()
+		} else {
+			errText = fmt.Sprintf("%d services and %d metrics failed", result.ServiceErrors, result.MetricErrors)
+		}
+	}
+
+	if err := s.runs.Finish(context.WithoutCancel(ctx), scanID, status, errText, time.Now()); err != nil {
+		s.logger.Error("failed to record scan run", "scan_id", scanID, "error", err)
+	}
+}
+
+func (s *Scheduler) runDetection(ctx context.Context, scanID, snapshotID int64) {
+	if s.detector == nil {
+		return
+	}
+
+	if _, err := s.detector.Run(ctx, snapshotID); err != nil {
+		s.logger.Error("label detection failed", "scan_id", scanID, "snapshot_id", snapshotID, "error", err)
+	}
+}
+
+func (s *Scheduler) runNotifications(ctx context.Context, scanID, snapshotID int64) {
+	if s.notifier == nil {
+		return
+	}
+
+	if err := s.notifier.Notify(ctx, snapshotID); err != nil {
+		s.logger.Error("notification failed", "scan_id", scanID, "snapshot_id", snapshotID, "error", err)
+	}
+}
+
 func (s *Scheduler) runCleanup(ctx context.Context, scanID int64) {
-	if s.db == nil || s.retention == 0 {
+	if s.db == nil || len(s.retention) == 0 {
 		return
 	}
 
@@ -222,4 +405,9 @@ type scanError string
 
 func (e scanError) Error() string { return string(e) }
 
-const ErrScanAlreadyRunning = scanError("scan already running")
+const (
+	ErrScanAlreadyRunning = scanError("scan already running")
+	ErrNoScanRunning      = scanError("no scan running")
+	ErrScanCancelled      = scanError("scan cancelled")
+	ErrUnknownProfile     = scanError("unknown scan profile")
+)
@@ -24,7 +24,7 @@ func (r *AnalysisRepository) Create(ctx context.Context, currentID, previousID i
 		INSERT INTO snapshot_analyses (current_snapshot_id, previous_snapshot_id, status, created_at)
 		VALUES (?, ?, ?, ?)
 	`
-	result, err := r.db.conn.ExecContext(ctx, query,
+	id, err := r.db.insert(ctx, query,
 		currentID,
 		previousID,
 		models.AnalysisStatusPending,
@@ -34,11 +34,6 @@ func (r *AnalysisRepository) Create(ctx context.Context, currentID, previousID i
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-
 	return &models.SnapshotAnalysis{
 		ID:                 id,
 		CurrentSnapshotID:  currentID,
@@ -54,7 +49,7 @@ func (r *AnalysisRepository) GetByPair(ctx context.Context, currentID, previousI
 		FROM snapshot_analyses
 		WHERE current_snapshot_id = ? AND previous_snapshot_id = ?
 	`
-	return r.scanOne(r.db.conn.QueryRowContext(ctx, query, currentID, previousID))
+	return r.scanOne(r.db.QueryRowContext(ctx, query, currentID, previousID))
 }
 
 func (r *AnalysisRepository) GetByID(ctx context.Context, id int64) (*models.SnapshotAnalysis, error) {
@@ -63,7 +58,7 @@ func (r *AnalysisRepository) GetByID(ctx context.Context, id int64) (*models.Sna
 		FROM snapshot_analyses
 		WHERE id = ?
 	`
-	return r.scanOne(r.db.conn.QueryRowContext(ctx, query, id))
+	return r.scanOne(r.db.QueryRowContext(ctx, query, id))
 }
 
 func (r *AnalysisRepository) ListBySnapshot(ctx context.Context, snapshotID int64) ([]models.SnapshotAnalysis, error) {
@@ -73,7 +68,7 @@ func (r *AnalysisRepository) ListBySnapshot(ctx context.Context, snapshotID int6
 		WHERE current_snapshot_id = ? OR previous_snapshot_id = ?
 		ORDER BY created_at DESC
 	`
-	rows, err := r.db.conn.QueryContext(ctx, query, snapshotID, snapshotID)
+	rows, err := r.db.QueryContext(ctx, query, snapshotID, snapshotID)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +102,7 @@ func (r *AnalysisRepository) Update(ctx context.Context, analysis *models.Snapsh
 		SET status = ?, result = ?, tool_calls = ?, error = ?, completed_at = ?
 		WHERE id = ?
 	`
-	_, err = r.db.conn.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		analysis.Status,
 		analysis.Result,
 		string(toolCallsJSON),
@@ -120,7 +115,7 @@ func (r *AnalysisRepository) Update(ctx context.Context, analysis *models.Snapsh
 
 func (r *AnalysisRepository) Delete(ctx context.Context, currentID, previousID int64) error {
 	query := `DELETE FROM snapshot_analyses WHERE current_snapshot_id = ? AND previous_snapshot_id = ?`
-	_, err := r.db.conn.ExecContext(ctx, query, currentID, previousID)
+	_, err := r.db.ExecContext(ctx, query, currentID, previousID)
 	return err
 }
 
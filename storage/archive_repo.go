@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+var ErrSnapshotRunning = errors.New("snapshot is still being collected")
+
+// ArchiveRepository reads and writes snapshots with everything collected for them, for
+// export archives.
+type ArchiveRepository struct {
+	db        *DB
+	snapshots *SnapshotsRepository
+	services  *ServicesRepository
+	labels    *LabelsRepository
+	analyses  *AnalysisRepository
+}
+
+func NewArchiveRepository(db *DB) *ArchiveRepository {
+	return &ArchiveRepository{
+		db:        db,
+		snapshots: NewSnapshotsRepository(db),
+		services:  NewServicesRepository(db),
+		labels:    NewLabelsRepository(db),
+		analyses:  NewAnalysisRepository(db),
+	}
+}
+
+// Export returns a snapshot with its services, metrics, labels and finished analyses. It is nil
+// if the snapshot does not exist.
+func (r *ArchiveRepository) Export(ctx context.Context, id int64) (*models.ArchivedSnapshot, error) {
+	snapshot, err := r.snapshots.GetByID(ctx, id)
+	if err != nil || snapshot == nil {
+		return nil, err
+	}
+	if snapshot.Status == models.ScanRunStatusRunning {
+		return nil, ErrSnapshotRunning
+	}
+
+	services, err := r.services.List(ctx, id, ServiceListOptions{Sort: "name", Order: "asc"})
+	if err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+
+	archived := &models.ArchivedSnapshot{
+		Snapshot: *snapshot,
+		Services: make([]models.ArchivedService, len(services)),
+	}
+	serviceIdx := make(map[int64]int, len(services))
+	for i, s := range services {
+		archived.Services[i] = models.ArchivedService{ServiceSnapshot: s, Metrics: []models.ArchivedMetric{}}
+		serviceIdx[s.ID] = i
+	}
+
+	metricRows, err := r.db.QueryContext(ctx, `
+		SELECT ms.id, ms.service_snapshot_id, ms.metric_name, ms.series_count, ms.label_count
+		FROM metric_snapshots ms
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		WHERE ss.snapshot_id = ?
+		ORDER BY ms.metric_name
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list metrics: %w", err)
+	}
+	defer metricRows.Close()
+
+	// metricPos locates a metric by its id: the index of its service and of the metric within it.
+	metricPos := make(map[int64][2]int)
+	for metricRows.Next() {
+		var m models.MetricSnapshot
+		if err := metricRows.Scan(&m.ID, &m.ServiceSnapshotID, &m.MetricName, &m.SeriesCount, &m.LabelCount); err != nil {
+			return nil, err
+		}
+		i, ok := serviceIdx[m.ServiceSnapshotID]
+		if !ok {
+			continue
+		}
+		metricPos[m.ID] = [2]int{i, len(archived.Services[i].Metrics)}
+		archived.Services[i].Metrics = append(archived.Services[i].Metrics, models.ArchivedMetric{MetricSnapshot: m})
+	}
+	if err := metricRows.Err(); err != nil {
+		return nil, err
+	}
+	metricRows.Close()
+
+	labelRows, err := r.db.QueryContext(ctx, `
+		SELECT ls.id, ls.metric_snapshot_id, ls.label_name, ls.unique_values_count, ls.sample_values, ls.series_without_label
+		FROM label_snapshots ls
+		JOIN metric_snapshots ms ON ms.id = ls.metric_snapshot_id
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		WHERE ss.snapshot_id = ?
+		ORDER BY ls.label_name
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list labels: %w", err)
+	}
+	defer labelRows.Close()
+
+	for labelRows.Next() {
+		l, err := r.labels.scanFromRows(labelRows)
+		if err != nil {
+			return nil, err
+		}
+		pos, ok := metricPos[l.MetricSnapshotID]
+		if !ok {
+			continue
+		}
+		metric := &archived.Services[pos[0]].Metrics[pos[1]]
+		metric.Labels = append(metric.Labels, *l)
+	}
+	if err := labelRows.Err(); err != nil {
+		return nil, err
+	}
+	labelRows.Close()
+
+	analyses, err := r.analyses.ListBySnapshot(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("list analyses: %w", err)
+	}
+	for _, a := range analyses {
+		finished := a.Status == models.AnalysisStatusCompleted || a.Status == models.AnalysisStatusFailed
+		if a.CurrentSnapshotID == id && finished {
+			archived.Analyses = append(archived.Analyses, a)
+		}
+	}
+
+	return archived, nil
+}
+
+// Import stores an archived snapshot with its services, metrics and labels under new IDs, and
+// returns the new snapshot ID. The scan run and baseline of the exporting instance are dropped.
+// Analyses reference two snapshots and are imported separately with ImportAnalysis.
+func (r *ArchiveRepository) Import(ctx context.Context, a *models.ArchivedSnapshot) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback snapshot import", "error", err)
+		}
+	}()
+
+	s := a.Snapshot
+	switch s.Status {
+	case "":
+		s.Status = models.ScanRunStatusCompleted
+	case models.ScanRunStatusRunning:
+		// No scan will ever finish it here.
+		s.Status = models.ScanRunStatusCancelled
+	}
+	snapshotID, err := tx.insert(ctx, `
+		INSERT INTO snapshots (source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.Source, s.Tenant, s.Status, s.ServiceFilter, s.Profile, s.CollectedAt.Format(time.RFC3339), s.ScanDurationMs, s.TotalServices, s.TotalSeries)
+	if err != nil {
+		return 0, fmt.Errorf("insert snapshot: %w", err)
+	}
+
+	labelStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO label_snapshots (metric_snapshot_id, label_name, unique_values_count, sample_values, series_without_label)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("prepare stmt: %w", err)
+	}
+	defer labelStmt.Close()
+
+	for _, svc := range a.Services {
+		serviceID, err := tx.insert(ctx, `
+			INSERT INTO service_snapshots (snapshot_id, service_name, total_series, metric_count)
+			VALUES (?, ?, ?, ?)
+		`, snapshotID, svc.ServiceName, svc.TotalSeries, svc.MetricCount)
+		if err != nil {
+			return 0, fmt.Errorf("insert service %s: %w", svc.ServiceName, err)
+		}
+
+		for _, m := range svc.Metrics {
+			metricID, err := tx.insert(ctx, `
+				INSERT INTO metric_snapshots (service_snapshot_id, metric_name, series_count, label_count)
+				VALUES (?, ?, ?, ?)
+			`, serviceID, m.MetricName, m.SeriesCount, m.LabelCount)
+			if err != nil {
+				return 0, fmt.Errorf("insert metric %s/%s: %w", svc.ServiceName, m.MetricName, err)
+			}
+
+			for _, l := range m.Labels {
+				sampleJSON, err := json.Marshal(l.SampleValues)
+				if err != nil {
+					return 0, fmt.Errorf("marshal sample values: %w", err)
+				}
+				if _, err := labelStmt.ExecContext(ctx, metricID, l.LabelName, l.UniqueValuesCount, string(sampleJSON), l.SeriesWithoutLabel); err != nil {
+					return 0, fmt.Errorf("insert label %s/%s/%s: %w", svc.ServiceName, m.MetricName, l.LabelName, err)
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return snapshotID, nil
+}
+
+// ImportAnalysis stores an archived analysis whose snapshot IDs are already remapped. It is
+// skipped if the pair of snapshots already has an analysis.
+func (r *ArchiveRepository) ImportAnalysis(ctx context.Context, a *models.SnapshotAnalysis) error {
+	toolCallsJSON, err := json.Marshal(a.ToolCalls)
+	if err != nil {
+		return fmt.Errorf("marshal tool calls: %w", err)
+	}
+
+	var completedAt *string
+	if a.CompletedAt != nil {
+		t := a.CompletedAt.Format(time.RFC3339)
+		completedAt = &t
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO snapshot_analyses (current_snapshot_id, previous_snapshot_id, status, result, tool_calls, error, created_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (current_snapshot_id, previous_snapshot_id) DO NOTHING
+	`, a.CurrentSnapshotID, a.PreviousSnapshotID, a.Status, a.Result, string(toolCallsJSON), a.Error,
+		a.CreatedAt.Format(time.RFC3339), completedAt)
+	return err
+}
+
+// Delete removes an imported snapshot with everything stored for it.
+func (r *ArchiveRepository) Delete(ctx context.Context, id int64) error {
+	return r.snapshots.Delete(ctx, id)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrBackupUnsupported = errors.New("online backups are only supported for sqlite storage, use pg_dump for postgres")
+
+const (
+	backupPrefix = "whodidthis-"
+	backupExt    = ".db"
+)
+
+// Backup writes a consistent copy of the SQLite database to path with VACUUM INTO while the
+// database stays in use; writes wait until the copy is done. path must not exist.
+func (db *DB) Backup(ctx context.Context, path string) error {
+	if db.dialect != DialectSQLite {
+		return ErrBackupUnsupported
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("backup database: %w", err)
+	}
+	return nil
+}
+
+// BackupToDir writes a timestamped backup into dir and deletes all but the newest keep backups
+// there; keep <= 0 keeps every backup. It returns the path of the new backup.
+func (db *DB) BackupToDir(ctx context.Context, dir string, keep int) (string, error) {
+	if db.dialect != DialectSQLite {
+		return "", ErrBackupUnsupported
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format("20060102-150405")+backupExt)
+	// Written under a temporary name so an interrupted backup is never taken for a complete one.
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := db.Backup(ctx, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("finish backup: %w", err)
+	}
+
+	if err := rotateBackups(dir, keep); err != nil {
+		slog.Warn("failed to delete old backups", "dir", dir, "error", err)
+	}
+	return path, nil
+}
+
+// rotateBackups deletes all but the newest keep backups in dir. Backup names sort by time.
+func rotateBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupExt) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		slog.Info("deleted old backup", "file", name)
+	}
+	return nil
+}
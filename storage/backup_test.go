@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+func countRows(t *testing.T, db *DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestBackupDuringWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "whodidthis.db")
+	db, err := New(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for range 3 {
+		createTestSnapshot(t, db, models.Snapshot{},
+			testMetric{service: "api", metric: "http_requests_total", series: 100, labels: map[string]int{"path": 10}},
+			testMetric{service: "worker", metric: "jobs_total", series: 5},
+		)
+	}
+
+	// A second connection, like the server while `whodidthis backup` runs, holds an open write.
+	writer, err := New(path)
+	if err != nil {
+		t.Fatalf("open writer: %v", err)
+	}
+	defer writer.Close()
+	tx, err := writer.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO snapshots (collected_at, total_services, total_series) VALUES (?, 0, 0)", testTime.Format("2006-01-02T15:04:05Z")); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.Backup(ctx, backupPath); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	backup, err := New(backupPath)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer backup.Close()
+
+	// The backup is the committed state when it was taken, without the open write.
+	want := map[string]int{"snapshots": 3, "service_snapshots": 6, "metric_snapshots": 6, "label_snapshots": 3}
+	for table, n := range want {
+		if got := countRows(t, backup, table); got != n {
+			t.Errorf("backup has %d %s, want %d", got, table, n)
+		}
+	}
+	if got := countRows(t, db, "snapshots"); got != 4 {
+		t.Errorf("database has %d snapshots after the commit, want 4", got)
+	}
+
+	if err := db.Backup(ctx, backupPath); err == nil {
+		t.Error("Backup overwrote an existing file")
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	backups := []string{
+		"whodidthis-20260301-030000.db",
+		"whodidthis-20260302-030000.db",
+		"whodidthis-20260303-030000.db",
+		"whodidthis-20260304-030000.db",
+		"whodidthis-20260305-030000.db",
+	}
+	others := []string{"notes.txt", "whodidthis-20260306-030000.db.tmp", "other.db"}
+	for _, name := range append(slices.Clone(backups), others...) {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rotateBackups(dir, 2); err != nil {
+		t.Fatalf("rotateBackups: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := append(slices.Clone(backups[3:]), others...)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+
+	if err := rotateBackups(dir, 0); err != nil {
+		t.Fatalf("rotateBackups with keep 0: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != len(want) {
+		t.Errorf("keep 0 deleted backups, %d files left", len(entries))
+	}
+}
+
+func TestBackupToDir(t *testing.T) {
+	db := openTestSQLite(t)
+	createTestSnapshot(t, db, models.Snapshot{}, testMetric{service: "api", metric: "up", series: 1})
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"whodidthis-20260301-030000.db", "whodidthis-20260302-030000.db"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := db.BackupToDir(context.Background(), dir, 2)
+	if err != nil {
+		t.Fatalf("BackupToDir: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "whodidthis-20260302-030000.db" || entries[1].Name() != filepath.Base(path) {
+		t.Errorf("backup dir holds %v, want the newest old backup and %s", entries, filepath.Base(path))
+	}
+
+	backup, err := New(path)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer backup.Close()
+	if got := countRows(t, backup, "snapshots"); got != 1 {
+		t.Errorf("backup has %d snapshots, want 1", got)
+	}
+}
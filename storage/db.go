@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+// Each dialect has its own migrations under migrations/<dialect>.
+//
+//go:embed migrations/*/*.sql
+var migrationsFS embed.FS
+
+// Dialect is the database engine behind a DB. Repositories write queries with ? placeholders
+// and SQL both engines understand; DB rewrites the placeholders for the dialect.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// migrationLockID is the PostgreSQL advisory lock held while migrating, so replicas
+// starting together don't apply the same migration twice.
+const migrationLockID = 7_277_641_003
+
+type DB struct {
+	conn    *sql.DB
+	dialect Dialect
+}
+
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+func (db *DB) Conn() *sql.DB {
+	return db.conn
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.conn.ExecContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.conn.QueryContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.conn.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.conn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.dialect}, nil
+}
+
+func (db *DB) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	return insertRow(ctx, db, db.dialect, query, args...)
+}
+
+// Tx is a transaction that rewrites placeholders like DB.
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return tx.Tx.PrepareContext(ctx, tx.dialect.rebind(query))
+}
+
+func (tx *Tx) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	return insertRow(ctx, tx, tx.dialect, query, args...)
+}
+
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertRow runs an INSERT and returns the id of the new row. PostgreSQL has no last insert id,
+// so the id is returned by the statement itself.
+func insertRow(ctx context.Context, q execQuerier, dialect Dialect, query string, args ...any) (int64, error) {
+	if dialect == DialectPostgres {
+		var id int64
+		err := q.QueryRowContext(ctx, strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// rebind rewrites ? placeholders to PostgreSQL's $1, $2, ... Question marks inside
+// quoted literals are kept.
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if c == '?' && !quoted {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// migrate applies the dialect's pending migrations, each in its own transaction. It runs on a
+// single connection because SQLite's foreign_keys pragma and PostgreSQL's advisory lock are
+// per connection.
+func (db *DB) migrate(ctx context.Context) error {
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if db.dialect == DialectPostgres {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+				slog.Error("failed to release migration lock", "error", err)
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	dir := "migrations/" + string(db.dialect)
+	entries, err := migrationsFS.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	if db.dialect == DialectSQLite {
+		// Foreign keys stay off while migrating so migrations can rebuild tables
+		// without cascading deletes; integrity is checked before each commit.
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys=OFF"); err != nil {
+			return fmt.Errorf("failed to disable foreign keys: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), "PRAGMA foreign_keys=ON"); err != nil {
+				slog.Error("failed to re-enable foreign keys", "error", err)
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		var count int
+		if err := conn.QueryRowContext(ctx, db.dialect.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), entry.Name()).Scan(&count); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", entry.Name(), err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		content, err := migrationsFS.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		slog.Info("applying migration", "dialect", db.dialect, "file", entry.Name())
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction for migration %s: %w", entry.Name(), err)
+		}
+
+		if _, err := tx.ExecContext(ctx, string(content)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to execute migration %s: %w", entry.Name(), err)
+		}
+
+		if db.dialect == DialectSQLite {
+			if err := checkForeignKeys(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %s broke foreign keys: %w", entry.Name(), err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, db.dialect.rebind("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"),
+			entry.Name(), time.Now().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", entry.Name(), err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) Stats(ctx context.Context) (*DBStats, error) {
+	var stats DBStats
+
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM snapshots")
+	if err := row.Scan(&stats.SnapshotsCount); err != nil {
+		return nil, err
+	}
+
+	row = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM service_snapshots")
+	if err := row.Scan(&stats.ServiceSnapshotsCount); err != nil {
+		return nil, err
+	}
+
+	row = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM metric_snapshots")
+	if err := row.Scan(&stats.MetricSnapshotsCount); err != nil {
+		return nil, err
+	}
+
+	row = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM label_snapshots")
+	if err := row.Scan(&stats.LabelSnapshotsCount); err != nil {
+		return nil, err
+	}
+
+	sizeQuery := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if db.dialect == DialectPostgres {
+		sizeQuery = "SELECT pg_database_size(current_database())"
+	}
+	row = db.QueryRowContext(ctx, sizeQuery)
+	if err := row.Scan(&stats.SizeBytes); err != nil {
+		stats.SizeBytes = 0
+	}
+
+	return &stats, nil
+}
+
+type DBStats struct {
+	SnapshotsCount        int64
+	ServiceSnapshotsCount int64
+	MetricSnapshotsCount  int64
+	LabelSnapshotsCount   int64
+	SizeBytes             int64
+}
+
+// Cleanup deletes snapshots older than the last tier and thins the rest down to the
+// tiers' resolutions, keeping pinned baselines. It returns the number of deleted snapshots.
+func (db *DB) Cleanup(ctx context.Context, tiers []RetentionTier) (int64, error) {
+	if len(tiers) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	cutoff := now.Add(-tiers[len(tiers)-1].MaxAge).Format(time.RFC3339)
+
+	// Due to CASCADE deletes, we only need to delete from snapshots
+	result, err := db.ExecContext(ctx,
+		"DELETE FROM snapshots WHERE collected_at < ? AND baseline_name IS NULL",
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup snapshots: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	thinned, err := db.thinSnapshots(ctx, tiers, now)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to thin snapshots: %w", err)
+	}
+	deleted += thinned
+
+	if _, err := db.ExecContext(ctx,
+		"DELETE FROM scan_runs WHERE started_at < ? AND status != ?",
+		cutoff, models.ScanRunStatusRunning,
+	); err != nil {
+		return deleted, fmt.Errorf("failed to cleanup scan runs: %w", err)
+	}
+
+	// PostgreSQL reclaims space with autovacuum.
+	if db.dialect == DialectSQLite {
+		if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+			slog.Warn("failed to vacuum database", "error", err)
+		}
+	}
+
+	return deleted, nil
+}
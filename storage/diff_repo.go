@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+type DiffRepository struct {
+	db *DB
+}
+
+func NewDiffRepository(db *DB) *DiffRepository {
+	return &DiffRepository{db: db}
+}
+
+type metricKey struct {
+	service string
+	metric  string
+}
+
+type snapshotContents struct {
+	services map[string]models.ServiceSnapshot
+	metrics  map[string]map[string]int
+	labels   map[metricKey]map[string]int
+}
+
+// Diff compares two snapshots at service, metric and label level.
+// Returns nil if either snapshot does not exist.
+func (r *DiffRepository) Diff(ctx context.Context, fromID, toID int64) (*models.SnapshotDiff, error) {
+	snapshots := NewSnapshotsRepository(r.db)
+
+	from, err := snapshots.GetByID(ctx, fromID)
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot %d: %w", fromID, err)
+	}
+	to, err := snapshots.GetByID(ctx, toID)
+	if err != nil {
+		return nil, fmt.Errorf("get snapshot %d: %w", toID, err)
+	}
+	if from == nil || to == nil {
+		return nil, nil
+	}
+
+	fromContents, err := r.loadContents(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	toContents, err := r.loadContents(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.SnapshotDiff{
+		FromSnapshotID:     from.ID,
+		ToSnapshotID:       to.ID,
+		FromCollectedAt:    from.CollectedAt,
+		ToCollectedAt:      to.CollectedAt,
+		FromTotalSeries:    from.TotalSeries,
+		ToTotalSeries:      to.TotalSeries,
+		SeriesDelta:        to.TotalSeries - from.TotalSeries,
+		SeriesDeltaPercent: deltaPercent(from.TotalSeries, to.TotalSeries),
+		AddedServices:      []string{},
+		RemovedServices:    []string{},
+		Services:           []models.ServiceDiff{},
+	}
+
+	for _, name := range unionKeys(fromContents.services, toContents.services) {
+		svc := diffService(name, fromContents, toContents)
+
+		switch svc.Status {
+		case models.DiffStatusAdded:
+			diff.AddedServices = append(diff.AddedServices, name)
+		case models.DiffStatusRemoved:
+			diff.RemovedServices = append(diff.RemovedServices, name)
+		case models.DiffStatusUnchanged:
+			diff.UnchangedServices++
+			continue
+		}
+
+		diff.Services = append(diff.Services, svc)
+	}
+
+	sort.SliceStable(diff.Services, func(i, j int) bool {
+		return abs(diff.Services[i].SeriesDelta) > abs(diff.Services[j].SeriesDelta)
+	})
+
+	return diff, nil
+}
+
+func diffService(name string, from, to *snapshotContents) models.ServiceDiff {
+	fromSvc, inFrom := from.services[name]
+	toSvc, inTo := to.services[name]
+
+	svc := models.ServiceDiff{
+		Name:               name,
+		Status:             diffStatus(inFrom, inTo),
+		FromSeries:         fromSvc.TotalSeries,
+		ToSeries:           toSvc.TotalSeries,
+		SeriesDelta:        toSvc.TotalSeries - fromSvc.TotalSeries,
+		SeriesDeltaPercent: deltaPercent(int64(fromSvc.TotalSeries), int64(toSvc.TotalSeries)),
+		FromMetricCount:    fromSvc.MetricCount,
+		ToMetricCount:      toSvc.MetricCount,
+	}
+
+	fromMetrics := from.metrics[name]
+	toMetrics := to.metrics[name]
+
+	for _, metricName := range unionKeys(fromMetrics, toMetrics) {
+		fromSeries, metricInFrom := fromMetrics[metricName]
+		toSeries, metricInTo := toMetrics[metricName]
+
+		metric := models.MetricDiff{
+			Name:               metricName,
+			Status:             diffStatus(metricInFrom, metricInTo),
+			FromSeries:         fromSeries,
+			ToSeries:           toSeries,
+			SeriesDelta:        toSeries - fromSeries,
+			SeriesDeltaPercent: deltaPercent(int64(fromSeries), int64(toSeries)),
+		}
+
+		switch metric.Status {
+		case models.DiffStatusAdded:
+			svc.AddedMetrics = append(svc.AddedMetrics, metricName)
+		case models.DiffStatusRemoved:
+			svc.RemovedMetrics = append(svc.RemovedMetrics, metricName)
+		default:
+			key := metricKey{service: name, metric: metricName}
+			metric.Labels = diffLabels(from.labels[key], to.labels[key])
+			if metric.SeriesDelta == 0 && len(metric.Labels) == 0 {
+				svc.UnchangedMetrics++
+				continue
+			}
+		}
+
+		svc.Metrics = append(svc.Metrics, metric)
+	}
+
+	sort.SliceStable(svc.Metrics, func(i, j int) bool {
+		return abs(svc.Metrics[i].SeriesDelta) > abs(svc.Metrics[j].SeriesDelta)
+	})
+
+	if svc.Status == models.DiffStatusChanged && svc.SeriesDelta == 0 && len(svc.Metrics) == 0 {
+		svc.Status = models.DiffStatusUnchanged
+	}
+
+	return svc
+}
+
+// diffLabels returns only the labels that were added, removed or changed their unique value count.
+func diffLabels(from, to map[string]int) []models.LabelDiff {
+	var labels []models.LabelDiff
+	for _, name := range unionKeys(from, to) {
+		fromValues, inFrom := from[name]
+		toValues, inTo := to[name]
+
+		if inFrom && inTo && fromValues == toValues {
+			continue
+		}
+
+		labels = append(labels, models.LabelDiff{
+			Name:              name,
+			Status:            diffStatus(inFrom, inTo),
+			FromUniqueValues:  fromValues,
+			ToUniqueValues:    toValues,
+			UniqueValuesDelta: toValues - fromValues,
+		})
+	}
+
+	sort.SliceStable(labels, func(i, j int) bool {
+		return abs(labels[i].UniqueValuesDelta) > abs(labels[j].UniqueValuesDelta)
+	})
+
+	return labels
+}
+
+func (r *DiffRepository) loadContents(ctx context.Context, snapshotID int64) (*snapshotContents, error) {
+	contents := &snapshotContents{
+		services: make(map[string]models.ServiceSnapshot),
+		metrics:  make(map[string]map[string]int),
+		labels:   make(map[metricKey]map[string]int),
+	}
+
+	serviceRows, err := r.db.QueryContext(ctx, `
+		SELECT id, snapshot_id, service_name, total_series, metric_count
+		FROM service_snapshots
+		WHERE snapshot_id = ?
+	`, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("query services: %w", err)
+	}
+	defer serviceRows.Close()
+
+	for serviceRows.Next() {
+		var s models.ServiceSnapshot
+		if err := serviceRows.Scan(&s.ID, &s.SnapshotID, &s.ServiceName, &s.TotalSeries, &s.MetricCount); err != nil {
+			return nil, err
+		}
+		contents.services[s.ServiceName] = s
+	}
+	if err := serviceRows.Err(); err != nil {
+		return nil, err
+	}
+
+	metricRows, err := r.db.QueryContext(ctx, `
+		SELECT ss.service_name, ms.metric_name, ms.series_count
+		FROM metric_snapshots ms
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		WHERE ss.snapshot_id = ?
+	`, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("query metrics: %w", err)
+	}
+	defer metricRows.Close()
+
+	for metricRows.Next() {
+		var serviceName, metricName string
+		var seriesCount int
+		if err := metricRows.Scan(&serviceName, &metricName, &seriesCount); err != nil {
+			return nil, err
+		}
+		if _, ok := contents.metrics[serviceName]; !ok {
+			contents.metrics[serviceName] = make(map[string]int)
+		}
+		contents.metrics[serviceName][metricName] = seriesCount
+	}
+	if err := metricRows.Err(); err != nil {
+		return nil, err
+	}
+
+	labelRows, err := r.db.QueryContext(ctx, `
+		SELECT ss.service_name, ms.metric_name, ls.label_name, ls.unique_values_count
+		FROM label_snapshots ls
+		JOIN metric_snapshots ms ON ms.id = ls.metric_snapshot_id
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		WHERE ss.snapshot_id = ?
+	`, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("query labels: %w", err)
+	}
+	defer labelRows.Close()
+
+	for labelRows.Next() {
+		var key metricKey
+		var labelName string
+		var uniqueValues int
+		if err := labelRows.Scan(&key.service, &key.metric, &labelName, &uniqueValues); err != nil {
+			return nil, err
+		}
+		if _, ok := contents.labels[key]; !ok {
+			contents.labels[key] = make(map[string]int)
+		}
+		contents.labels[key][labelName] = uniqueValues
+	}
+
+	return contents, labelRows.Err()
+}
+
+func diffStatus(inFrom, inTo bool) models.DiffStatus {
+	switch {
+	case inFrom && !inTo:
+		return models.DiffStatusRemoved
+	case !inFrom && inTo:
+		return models.DiffStatusAdded
+	default:
+		return models.DiffStatusChanged
+	}
+}
+
+// deltaPercent returns the relative change from -> to. A value appearing from zero counts as 100%.
+func deltaPercent(from, to int64) float64 {
+	if from > 0 {
+		return float64(to-from) / float64(from) * 100
+	}
+	if to > 0 {
+		return 100
+	}
+	return 0
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func abs[T int | int64](v T) T {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+func TestDiff(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+
+		fromID := createTestSnapshot(t, db, models.Snapshot{},
+			testMetric{service: "api", metric: "http_requests_total", series: 100, labels: map[string]int{"path": 10, "method": 4}},
+			testMetric{service: "api", metric: "up", series: 1},
+			testMetric{service: "worker", metric: "jobs_total", series: 50},
+			testMetric{service: "db", metric: "queries_total", series: 5, labels: map[string]int{"table": 5}},
+		)
+		toID := createTestSnapshot(t, db, models.Snapshot{},
+			testMetric{service: "api", metric: "http_requests_total", series: 150, labels: map[string]int{"path": 20, "method": 4, "status": 5}},
+			testMetric{service: "api", metric: "up", series: 1},
+			testMetric{service: "api", metric: "grpc_requests_total", series: 30},
+			testMetric{service: "db", metric: "queries_total", series: 5, labels: map[string]int{"table": 5}},
+			testMetric{service: "billing", metric: "invoices_total", series: 7},
+		)
+
+		diff, err := NewDiffRepository(db).Diff(ctx, fromID, toID)
+		if err != nil {
+			t.Fatalf("Diff: %v", err)
+		}
+
+		if diff.FromTotalSeries != 156 || diff.ToTotalSeries != 193 || diff.SeriesDelta != 37 {
+			t.Errorf("totals = %d -> %d (%+d), want 156 -> 193 (+37)", diff.FromTotalSeries, diff.ToTotalSeries, diff.SeriesDelta)
+		}
+		if !slices.Equal(diff.AddedServices, []string{"billing"}) || !slices.Equal(diff.RemovedServices, []string{"worker"}) {
+			t.Errorf("added %v, removed %v; want [billing], [worker]", diff.AddedServices, diff.RemovedServices)
+		}
+		if diff.UnchangedServices != 1 {
+			t.Errorf("unchanged services = %d, want 1", diff.UnchangedServices)
+		}
+
+		var names []string
+		for _, s := range diff.Services {
+			names = append(names, s.Name)
+		}
+		if want := []string{"api", "worker", "billing"}; !slices.Equal(names, want) {
+			t.Fatalf("services = %v, want %v ordered by absolute delta", names, want)
+		}
+
+		api := diff.Services[0]
+		if api.Status != models.DiffStatusChanged || api.SeriesDelta != 80 || api.FromMetricCount != 2 || api.ToMetricCount != 3 {
+			t.Errorf("api = %+v", api)
+		}
+		if !slices.Equal(api.AddedMetrics, []string{"grpc_requests_total"}) || len(api.RemovedMetrics) != 0 || api.UnchangedMetrics != 1 {
+			t.Errorf("api metrics: added %v, removed %v, unchanged %d", api.AddedMetrics, api.RemovedMetrics, api.UnchangedMetrics)
+		}
+		if len(api.Metrics) != 2 || api.Metrics[0].Name != "http_requests_total" || api.Metrics[1].Name != "grpc_requests_total" {
+			t.Fatalf("api metric diffs = %+v", api.Metrics)
+		}
+
+		http := api.Metrics[0]
+		if http.SeriesDelta != 50 || http.SeriesDeltaPercent != 50 {
+			t.Errorf("http_requests_total delta = %d (%.1f%%), want 50 (50%%)", http.SeriesDelta, http.SeriesDeltaPercent)
+		}
+		wantLabels := []models.LabelDiff{
+			{Name: "path", Status: models.DiffStatusChanged, FromUniqueValues: 10, ToUniqueValues: 20, UniqueValuesDelta: 10},
+			{Name: "status", Status: models.DiffStatusAdded, ToUniqueValues: 5, UniqueValuesDelta: 5},
+		}
+		if !reflect.DeepEqual(http.Labels, wantLabels) {
+			t.Errorf("labels = %+v, want %+v", http.Labels, wantLabels)
+		}
+
+		worker := diff.Services[1]
+		if worker.Status != models.DiffStatusRemoved || worker.SeriesDelta != -50 || worker.SeriesDeltaPercent != -100 {
+			t.Errorf("worker = %+v", worker)
+		}
+		if billing := diff.Services[2]; billing.Status != models.DiffStatusAdded || billing.SeriesDeltaPercent != 100 {
+			t.Errorf("billing = %+v", billing)
+		}
+
+		missing, err := NewDiffRepository(db).Diff(ctx, fromID, toID+100)
+		if err != nil || missing != nil {
+			t.Errorf("Diff with a missing snapshot = %v, %v; want nil, nil", missing, err)
+		}
+	})
+}
+
+func TestDiffLabels(t *testing.T) {
+	got := diffLabels(
+		map[string]int{"path": 10, "method": 4, "instance": 3},
+		map[string]int{"path": 2, "method": 4, "pod": 30},
+	)
+	want := []models.LabelDiff{
+		{Name: "pod", Status: models.DiffStatusAdded, ToUniqueValues: 30, UniqueValuesDelta: 30},
+		{Name: "path", Status: models.DiffStatusChanged, FromUniqueValues: 10, ToUniqueValues: 2, UniqueValuesDelta: -8},
+		{Name: "instance", Status: models.DiffStatusRemoved, FromUniqueValues: 3, UniqueValuesDelta: -3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLabels = %+v, want %+v", got, want)
+	}
+
+	if got := diffLabels(map[string]int{"a": 1}, map[string]int{"a": 1}); len(got) != 0 {
+		t.Errorf("diffLabels of equal labels = %+v, want none", got)
+	}
+}
+
+func TestDeltaPercent(t *testing.T) {
+	tests := []struct {
+		from, to int64
+		want     float64
+	}{
+		{100, 150, 50},
+		{100, 50, -50},
+		{100, 0, -100},
+		{0, 10, 100},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := deltaPercent(tt.from, tt.to); got != tt.want {
+			t.Errorf("deltaPercent(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
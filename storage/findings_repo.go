@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+type FindingsRepository struct {
+	db *DB
+}
+
+func NewFindingsRepository(db *DB) *FindingsRepository {
+	return &FindingsRepository{db: db}
+}
+
+// Replace stores the findings of a snapshot, discarding any earlier findings for it.
+func (r *FindingsRepository) Replace(ctx context.Context, snapshotID int64, findings []*models.LabelFinding) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback findings batch", "error", err)
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM label_findings WHERE snapshot_id = ?", snapshotID); err != nil {
+		return fmt.Errorf("delete findings: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO label_findings (snapshot_id, service_name, metric_name, label_name, rule, severity,
+			unique_values, previous_unique_values, pattern, examples, message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare stmt: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, f := range findings {
+		examplesJSON, err := json.Marshal(f.Examples)
+		if err != nil {
+			return fmt.Errorf("marshal examples for %s: %w", f.LabelName, err)
+		}
+		if _, err = stmt.ExecContext(ctx,
+			snapshotID,
+			f.ServiceName,
+			f.MetricName,
+			f.LabelName,
+			f.Rule,
+			f.Severity,
+			f.UniqueValues,
+			f.PreviousUniqueValues,
+			f.Pattern,
+			string(examplesJSON),
+			f.Message,
+		); err != nil {
+			return fmt.Errorf("insert finding %s: %w", f.LabelName, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *FindingsRepository) ListBySnapshot(ctx context.Context, snapshotID int64) ([]models.LabelFinding, error) {
+	query := `
+		SELECT id, snapshot_id, service_name, metric_name, label_name, rule, severity,
+			unique_values, previous_unique_values, pattern, examples, message
+		FROM label_findings
+		WHERE snapshot_id = ?
+		ORDER BY CASE severity WHEN 'critical' THEN 0 ELSE 1 END, unique_values DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []models.LabelFinding
+	for rows.Next() {
+		var f models.LabelFinding
+		var pattern, examplesJSON sql.NullString
+		if err := rows.Scan(
+			&f.ID,
+			&f.SnapshotID,
+			&f.ServiceName,
+			&f.MetricName,
+			&f.LabelName,
+			&f.Rule,
+			&f.Severity,
+			&f.UniqueValues,
+			&f.PreviousUniqueValues,
+			&pattern,
+			&examplesJSON,
+			&f.Message,
+		); err != nil {
+			return nil, err
+		}
+		f.Pattern = pattern.String
+		if examplesJSON.Valid && examplesJSON.String != "" {
+			if err := json.Unmarshal([]byte(examplesJSON.String), &f.Examples); err != nil {
+				return nil, err
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
@@ -10,19 +10,28 @@ import (
 type SnapshotsRepo interface {
 	Create(ctx context.Context, s *models.Snapshot) (int64, error)
 	Update(ctx context.Context, s *models.Snapshot) error
-	GetLatest(ctx context.Context) (*models.Snapshot, error)
+	GetLatest(ctx context.Context, filter SnapshotFilter) (*models.Snapshot, error)
 	GetByID(ctx context.Context, id int64) (*models.Snapshot, error)
-	List(ctx context.Context, limit int) ([]models.Snapshot, error)
-	GetByDate(ctx context.Context, date time.Time) (*models.Snapshot, error)
-	GetNDaysAgo(ctx context.Context, days int) (*models.Snapshot, error)
+	List(ctx context.Context, filter SnapshotFilter, limit int) ([]models.Snapshot, error)
+	GetByDate(ctx context.Context, date time.Time, filter SnapshotFilter) (*models.Snapshot, error)
+	GetNDaysAgo(ctx context.Context, days int, filter SnapshotFilter) (*models.Snapshot, error)
+	GetPrevious(ctx context.Context, id int64) (*models.Snapshot, error)
+	GetComparison(ctx context.Context, id int64) (*models.Snapshot, error)
+	ListBaselines(ctx context.Context, filter SnapshotFilter) ([]models.Snapshot, error)
+	Pin(ctx context.Context, id int64, baseline models.Baseline) error
+	Unpin(ctx context.Context, id int64) error
+	ListScopes(ctx context.Context) ([]SnapshotFilter, error)
+	Delete(ctx context.Context, id int64) error
 	DeleteOlderThan(ctx context.Context, days int) (int64, error)
 }
 
 type ServicesRepo interface {
 	Create(ctx context.Context, s *models.ServiceSnapshot) (int64, error)
 	CreateBatch(ctx context.Context, services []*models.ServiceSnapshot) error
+	Delete(ctx context.Context, id int64) error
 	List(ctx context.Context, snapshotID int64, opts ServiceListOptions) ([]models.ServiceSnapshot, error)
 	GetByName(ctx context.Context, snapshotID int64, name string) (*models.ServiceSnapshot, error)
+	History(ctx context.Context, name string, filter SnapshotFilter, limit int) ([]models.ServiceHistoryPoint, error)
 }
 
 type MetricsRepo interface {
@@ -30,6 +39,8 @@ type MetricsRepo interface {
 	CreateBatch(ctx context.Context, metrics []*models.MetricSnapshot) error
 	List(ctx context.Context, serviceSnapshotID int64, opts MetricListOptions) ([]models.MetricSnapshot, error)
 	GetByName(ctx context.Context, serviceSnapshotID int64, name string) (*models.MetricSnapshot, error)
+	ListTop(ctx context.Context, snapshotID int64, opts TopMetricsOptions) ([]models.TopMetric, error)
+	History(ctx context.Context, serviceName, metricName string, filter SnapshotFilter, limit int) ([]models.MetricHistoryPoint, error)
 }
 
 type LabelsRepo interface {
@@ -37,6 +48,8 @@ type LabelsRepo interface {
 	CreateBatch(ctx context.Context, labels []*models.LabelSnapshot) error
 	List(ctx context.Context, metricSnapshotID int64) ([]models.LabelSnapshot, error)
 	GetByName(ctx context.Context, metricSnapshotID int64, name string) (*models.LabelSnapshot, error)
+	ListBySnapshot(ctx context.Context, snapshotID int64) ([]models.SnapshotLabel, error)
+	ListDropSuggestions(ctx context.Context, snapshotID int64, limit int) ([]models.DropSuggestion, error)
 }
 
 type AnalysisRepo interface {
@@ -47,3 +60,34 @@ type AnalysisRepo interface {
 	Update(ctx context.Context, analysis *models.SnapshotAnalysis) error
 	Delete(ctx context.Context, currentID, previousID int64) error
 }
+
+type DiffRepo interface {
+	Diff(ctx context.Context, fromID, toID int64) (*models.SnapshotDiff, error)
+}
+
+type FindingsRepo interface {
+	Replace(ctx context.Context, snapshotID int64, findings []*models.LabelFinding) error
+	ListBySnapshot(ctx context.Context, snapshotID int64) ([]models.LabelFinding, error)
+}
+
+type TSDBStatsRepo interface {
+	Create(ctx context.Context, o *models.TSDBOverview) error
+	Get(ctx context.Context, snapshotID int64) (*models.TSDBOverview, error)
+}
+
+type ScanRunsRepo interface {
+	Create(ctx context.Context, startedAt time.Time) (*models.ScanRun, error)
+	Finish(ctx context.Context, id int64, status models.ScanRunStatus, errText string, finishedAt time.Time) error
+	FailInterrupted(ctx context.Context) (int64, error)
+	AddFailures(ctx context.Context, failures []*models.ScanFailure) error
+	GetByID(ctx context.Context, id int64) (*models.ScanRun, error)
+	List(ctx context.Context, limit int) ([]models.ScanRun, error)
+	ListFailures(ctx context.Context, runID, snapshotID int64) ([]models.ScanFailure, error)
+}
+
+type ArchiveRepo interface {
+	Export(ctx context.Context, id int64) (*models.ArchivedSnapshot, error)
+	Import(ctx context.Context, a *models.ArchivedSnapshot) (int64, error)
+	ImportAnalysis(ctx context.Context, a *models.SnapshotAnalysis) error
+	Delete(ctx context.Context, id int64) error
+}
@@ -26,23 +26,24 @@ func (r *LabelsRepository) Create(ctx context.Context, l *models.LabelSnapshot)
 	}
 
 	query := `
-		INSERT INTO label_snapshots (metric_snapshot_id, label_name, unique_values_count, sample_values)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO label_snapshots (metric_snapshot_id, label_name, unique_values_count, sample_values, series_without_label)
+		VALUES (?, ?, ?, ?, ?)
 	`
-	result, err := r.db.conn.ExecContext(ctx, query,
+	id, err := r.db.insert(ctx, query,
 		l.MetricSnapshotID,
 		l.LabelName,
 		l.UniqueValuesCount,
 		string(sampleJSON),
+		l.SeriesWithoutLabel,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("insert label snapshot: %w", err)
 	}
-	return result.LastInsertId()
+	return id, nil
 }
 
 func (r *LabelsRepository) CreateBatch(ctx context.Context, labels []*models.LabelSnapshot) error {
-	tx, err := r.db.conn.BeginTx(ctx, nil)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
@@ -53,8 +54,8 @@ func (r *LabelsRepository) CreateBatch(ctx context.Context, labels []*models.Lab
 	}()
 
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO label_snapshots (metric_snapshot_id, label_name, unique_values_count, sample_values)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO label_snapshots (metric_snapshot_id, label_name, unique_values_count, sample_values, series_without_label)
+		VALUES (?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("prepare stmt: %w", err)
@@ -66,7 +67,7 @@ func (r *LabelsRepository) CreateBatch(ctx context.Context, labels []*models.Lab
 		if err != nil {
 			return fmt.Errorf("marshal sample values for %s: %w", l.LabelName, err)
 		}
-		if _, err = stmt.ExecContext(ctx, l.MetricSnapshotID, l.LabelName, l.UniqueValuesCount, string(sampleJSON)); err != nil {
+		if _, err = stmt.ExecContext(ctx, l.MetricSnapshotID, l.LabelName, l.UniqueValuesCount, string(sampleJSON), l.SeriesWithoutLabel); err != nil {
 			return fmt.Errorf("insert label %s: %w", l.LabelName, err)
 		}
 	}
@@ -76,12 +77,12 @@ func (r *LabelsRepository) CreateBatch(ctx context.Context, labels []*models.Lab
 
 func (r *LabelsRepository) List(ctx context.Context, metricSnapshotID int64) ([]models.LabelSnapshot, error) {
 	query := `
-		SELECT id, metric_snapshot_id, label_name, unique_values_count, sample_values
+		SELECT id, metric_snapshot_id, label_name, unique_values_count, sample_values, series_without_label
 		FROM label_snapshots
 		WHERE metric_snapshot_id = ?
 		ORDER BY unique_values_count DESC
 	`
-	rows, err := r.db.conn.QueryContext(ctx, query, metricSnapshotID)
+	rows, err := r.db.QueryContext(ctx, query, metricSnapshotID)
 	if err != nil {
 		return nil, err
 	}
@@ -100,15 +101,16 @@ func (r *LabelsRepository) List(ctx context.Context, metricSnapshotID int64) ([]
 
 func (r *LabelsRepository) GetByName(ctx context.Context, metricSnapshotID int64, name string) (*models.LabelSnapshot, error) {
 	query := `
-		SELECT id, metric_snapshot_id, label_name, unique_values_count, sample_values
+		SELECT id, metric_snapshot_id, label_name, unique_values_count, sample_values, series_without_label
 		FROM label_snapshots
 		WHERE metric_snapshot_id = ? AND label_name = ?
 	`
-	row := r.db.conn.QueryRowContext(ctx, query, metricSnapshotID, name)
+	row := r.db.QueryRowContext(ctx, query, metricSnapshotID, name)
 
 	var l models.LabelSnapshot
 	var sampleJSON sql.NullString
-	err := row.Scan(&l.ID, &l.MetricSnapshotID, &l.LabelName, &l.UniqueValuesCount, &sampleJSON)
+	var seriesWithout sql.NullInt64
+	err := row.Scan(&l.ID, &l.MetricSnapshotID, &l.LabelName, &l.UniqueValuesCount, &sampleJSON, &seriesWithout)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -120,14 +122,58 @@ func (r *LabelsRepository) GetByName(ctx context.Context, metricSnapshotID int64
 			return nil, err
 		}
 	}
+	l.SeriesWithoutLabel = nullIntPtr(seriesWithout)
 	return &l, nil
 }
 
+// ListBySnapshot returns every label of every metric in a snapshot.
+func (r *LabelsRepository) ListBySnapshot(ctx context.Context, snapshotID int64) ([]models.SnapshotLabel, error) {
+	query := `
+		SELECT ss.service_name, ms.metric_name, ms.series_count,
+			ls.id, ls.metric_snapshot_id, ls.label_name, ls.unique_values_count, ls.sample_values
+		FROM label_snapshots ls
+		JOIN metric_snapshots ms ON ms.id = ls.metric_snapshot_id
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		WHERE ss.snapshot_id = ?
+	`
+	rows, err := r.db.QueryContext(ctx, query, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []models.SnapshotLabel
+	for rows.Next() {
+		var l models.SnapshotLabel
+		var sampleJSON sql.NullString
+		if err := rows.Scan(
+			&l.ServiceName,
+			&l.MetricName,
+			&l.SeriesCount,
+			&l.ID,
+			&l.MetricSnapshotID,
+			&l.LabelName,
+			&l.UniqueValuesCount,
+			&sampleJSON,
+		); err != nil {
+			return nil, err
+		}
+		if sampleJSON.Valid && sampleJSON.String != "" {
+			if err := json.Unmarshal([]byte(sampleJSON.String), &l.SampleValues); err != nil {
+				return nil, err
+			}
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
+
 func (r *LabelsRepository) scanFromRows(rows *sql.Rows) (*models.LabelSnapshot, error) {
 	var l models.LabelSnapshot
 	var sampleJSON sql.NullString
+	var seriesWithout sql.NullInt64
 
-	err := rows.Scan(&l.ID, &l.MetricSnapshotID, &l.LabelName, &l.UniqueValuesCount, &sampleJSON)
+	err := rows.Scan(&l.ID, &l.MetricSnapshotID, &l.LabelName, &l.UniqueValuesCount, &sampleJSON, &seriesWithout)
 	if err != nil {
 		return nil, err
 	}
@@ -137,5 +183,55 @@ func (r *LabelsRepository) scanFromRows(rows *sql.Rows) (*models.LabelSnapshot,
 			return nil, err
 		}
 	}
+	l.SeriesWithoutLabel = nullIntPtr(seriesWithout)
 	return &l, nil
 }
+
+// ListDropSuggestions ranks labels of a snapshot by how many series dropping them would remove.
+func (r *LabelsRepository) ListDropSuggestions(ctx context.Context, snapshotID int64, limit int) ([]models.DropSuggestion, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT ss.service_name, ms.metric_name, ls.label_name, ls.unique_values_count,
+			ms.series_count, ls.series_without_label
+		FROM label_snapshots ls
+		JOIN metric_snapshots ms ON ms.id = ls.metric_snapshot_id
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		WHERE ss.snapshot_id = ?
+			AND ls.series_without_label IS NOT NULL
+			AND ls.series_without_label < ms.series_count
+		ORDER BY ms.series_count - ls.series_without_label DESC
+		LIMIT ?
+	`
+	rows, err := r.db.QueryContext(ctx, query, snapshotID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.DropSuggestion
+	for rows.Next() {
+		var s models.DropSuggestion
+		if err := rows.Scan(&s.ServiceName, &s.MetricName, &s.LabelName, &s.UniqueValues, &s.SeriesCount, &s.SeriesAfter); err != nil {
+			return nil, err
+		}
+		s.SeriesReduction = s.SeriesCount - s.SeriesAfter
+		if s.SeriesCount > 0 {
+			s.ReductionPercent = float64(s.SeriesReduction) / float64(s.SeriesCount) * 100
+		}
+		s.Message = fmt.Sprintf("dropping %s from %s in %s removes ~%.0f%% of its series (%d -> %d)",
+			s.LabelName, s.MetricName, s.ServiceName, s.ReductionPercent, s.SeriesCount, s.SeriesAfter)
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
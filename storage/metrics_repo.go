@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/illenko/whodidthis/models"
 )
@@ -23,7 +25,7 @@ func (r *MetricsRepository) Create(ctx context.Context, m *models.MetricSnapshot
 		INSERT INTO metric_snapshots (service_snapshot_id, metric_name, series_count, label_count)
 		VALUES (?, ?, ?, ?)
 	`
-	result, err := r.db.conn.ExecContext(ctx, query,
+	id, err := r.db.insert(ctx, query,
 		m.ServiceSnapshotID,
 		m.MetricName,
 		m.SeriesCount,
@@ -32,11 +34,11 @@ func (r *MetricsRepository) Create(ctx context.Context, m *models.MetricSnapshot
 	if err != nil {
 		return 0, fmt.Errorf("insert metric snapshot: %w", err)
 	}
-	return result.LastInsertId()
+	return id, nil
 }
 
 func (r *MetricsRepository) CreateBatch(ctx context.Context, metrics []*models.MetricSnapshot) error {
-	tx, err := r.db.conn.BeginTx(ctx, nil)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
@@ -91,7 +93,7 @@ func (r *MetricsRepository) List(ctx context.Context, serviceSnapshotID int64, o
 		}
 	}
 
-	rows, err := r.db.conn.QueryContext(ctx, query, serviceSnapshotID)
+	rows, err := r.db.QueryContext(ctx, query, serviceSnapshotID)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +117,7 @@ func (r *MetricsRepository) GetByName(ctx context.Context, serviceSnapshotID int
 		WHERE service_snapshot_id = ? AND metric_name = ?
 	`
 	var m models.MetricSnapshot
-	err := r.db.conn.QueryRowContext(ctx, query, serviceSnapshotID, name).Scan(
+	err := r.db.QueryRowContext(ctx, query, serviceSnapshotID, name).Scan(
 		&m.ID, &m.ServiceSnapshotID, &m.MetricName, &m.SeriesCount, &m.LabelCount,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -126,3 +128,153 @@ func (r *MetricsRepository) GetByName(ctx context.Context, serviceSnapshotID int
 	}
 	return &m, nil
 }
+
+type TopMetricsOptions struct {
+	PreviousSnapshotID int64  // 0 disables growth comparison
+	Sort               string // "series", "growth", "growth_percent"
+	MinSeries          int    // ignore metrics with fewer series in the current snapshot
+	Limit              int    // 0 returns all metrics
+}
+
+// ListTop ranks metrics across every service in a snapshot, optionally compared with an earlier snapshot.
+func (r *MetricsRepository) ListTop(ctx context.Context, snapshotID int64, opts TopMetricsOptions) ([]models.TopMetric, error) {
+	query := `
+		SELECT ss.service_name, ms.metric_name, ms.series_count, ms.label_count,
+			COALESCE(prev.series_count, 0) AS previous_series,
+			prev.series_count IS NULL AS is_new,
+			ms.series_count - COALESCE(prev.series_count, 0) AS delta,
+			CASE
+				WHEN COALESCE(prev.series_count, 0) > 0 THEN (ms.series_count - prev.series_count) * 100.0 / prev.series_count
+				WHEN ms.series_count > 0 THEN 100.0
+				ELSE 0.0
+			END AS delta_percent
+		FROM metric_snapshots ms
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		LEFT JOIN (
+			SELECT pss.service_name, pms.metric_name, pms.series_count
+			FROM metric_snapshots pms
+			JOIN service_snapshots pss ON pss.id = pms.service_snapshot_id
+			WHERE pss.snapshot_id = ?
+		) prev ON prev.service_name = ss.service_name AND prev.metric_name = ms.metric_name
+		WHERE ss.snapshot_id = ? AND ms.series_count >= ?
+	`
+
+	switch opts.Sort {
+	case "growth":
+		query += " ORDER BY delta DESC, ms.series_count DESC"
+	case "growth_percent":
+		query += " ORDER BY delta_percent DESC, delta DESC"
+	default:
+		query += " ORDER BY ms.series_count DESC"
+	}
+
+	args := []interface{}{opts.PreviousSnapshotID, snapshotID, opts.MinSeries}
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []models.TopMetric
+	for rows.Next() {
+		var m models.TopMetric
+		if err := rows.Scan(
+			&m.ServiceName,
+			&m.MetricName,
+			&m.SeriesCount,
+			&m.LabelCount,
+			&m.PreviousSeriesCount,
+			&m.New,
+			&m.SeriesDelta,
+			&m.SeriesDeltaPercent,
+		); err != nil {
+			return nil, err
+		}
+		if opts.PreviousSnapshotID == 0 {
+			m.PreviousSeriesCount, m.SeriesDelta, m.SeriesDeltaPercent, m.New = 0, 0, 0, false
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// History returns the metric of a service across snapshots in chronological order,
+// including unique value counts per label. If limit > 0, only the most recent limit snapshots are returned.
+func (r *MetricsRepository) History(ctx context.Context, serviceName, metricName string, filter SnapshotFilter, limit int) ([]models.MetricHistoryPoint, error) {
+	where, filterArgs := filter.where("s")
+	from := `
+		FROM metric_snapshots ms
+		JOIN service_snapshots ss ON ss.id = ms.service_snapshot_id
+		JOIN snapshots s ON s.id = ss.snapshot_id
+		WHERE ss.service_name = ? AND ms.metric_name = ?` + where + `
+		ORDER BY s.collected_at DESC, s.id DESC`
+	args := append([]interface{}{serviceName, metricName}, filterArgs...)
+	if limit > 0 {
+		from += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT ms.id, s.id, s.source, s.tenant, s.collected_at, ms.series_count, ms.label_count`+from, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.MetricHistoryPoint
+	pointByMetricID := make(map[int64]int)
+	for rows.Next() {
+		var metricID int64
+		var collectedAt string
+		p := models.MetricHistoryPoint{Labels: make(map[string]int)}
+		if err := rows.Scan(&metricID, &p.SnapshotID, &p.Source, &p.Tenant, &collectedAt, &p.SeriesCount, &p.LabelCount); err != nil {
+			return nil, err
+		}
+		if p.CollectedAt, err = time.Parse(time.RFC3339, collectedAt); err != nil {
+			return nil, err
+		}
+		pointByMetricID[metricID] = len(points)
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(points) > 0 {
+		// Join the labels against the same page of points rather than binding every ID, so
+		// any limit stays one parameter.
+		labelQuery := `
+			SELECT ls.metric_snapshot_id, ls.label_name, ls.unique_values_count
+			FROM label_snapshots ls
+			JOIN (SELECT ms.id` + from + `) page ON page.id = ls.metric_snapshot_id`
+
+		labelRows, err := r.db.QueryContext(ctx, labelQuery, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer labelRows.Close()
+
+		for labelRows.Next() {
+			var metricID int64
+			var labelName string
+			var uniqueValues int
+			if err := labelRows.Scan(&metricID, &labelName, &uniqueValues); err != nil {
+				return nil, err
+			}
+			if i, ok := pointByMetricID[metricID]; ok {
+				points[i].Labels[labelName] = uniqueValues
+			}
+		}
+		if err := labelRows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.Reverse(points)
+	return points, nil
+}
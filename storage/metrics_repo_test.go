@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+func TestMetricsHistory(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		var ids []int64
+		for i := range 4 {
+			ids = append(ids, createTestSnapshot(t, db,
+				models.Snapshot{Source: "prod", CollectedAt: testTime.Add(time.Duration(i) * time.Hour)},
+				testMetric{service: "api", metric: "http_requests_total", series: 100 * (i + 1), labels: map[string]int{"path": 10 * (i + 1), "method": 4}},
+				testMetric{service: "api", metric: "up", series: 1},
+			))
+		}
+		createTestSnapshot(t, db,
+			models.Snapshot{Source: "staging", CollectedAt: testTime.Add(10 * time.Hour)},
+			testMetric{service: "api", metric: "http_requests_total", series: 7, labels: map[string]int{"path": 7}},
+		)
+
+		repo := NewMetricsRepository(db)
+
+		tests := []struct {
+			name    string
+			filter  SnapshotFilter
+			limit   int
+			wantIDs []int64
+		}{
+			{"all", SnapshotFilter{Source: "prod"}, 0, ids},
+			{"limited", SnapshotFilter{Source: "prod"}, 2, ids[2:]},
+			{"limit beyond the history", SnapshotFilter{Source: "prod"}, 1 << 20, ids},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				points, err := repo.History(ctx, "api", "http_requests_total", tt.filter, tt.limit)
+				if err != nil {
+					t.Fatalf("History: %v", err)
+				}
+				if len(points) != len(tt.wantIDs) {
+					t.Fatalf("got %d points, want %d", len(points), len(tt.wantIDs))
+				}
+				for i, p := range points {
+					n := int(tt.wantIDs[i] - ids[0] + 1)
+					if p.SnapshotID != tt.wantIDs[i] {
+						t.Errorf("point %d: snapshot %d, want %d", i, p.SnapshotID, tt.wantIDs[i])
+					}
+					if p.SeriesCount != 100*n {
+						t.Errorf("point %d: series %d, want %d", i, p.SeriesCount, 100*n)
+					}
+					if len(p.Labels) != 2 || p.Labels["path"] != 10*n || p.Labels["method"] != 4 {
+						t.Errorf("point %d: labels %v", i, p.Labels)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestMetricsListTop(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		previous := createTestSnapshot(t, db, models.Snapshot{},
+			testMetric{service: "api", metric: "http_requests_total", series: 200},
+			testMetric{service: "api", metric: "up", series: 10},
+			testMetric{service: "worker", metric: "jobs_total", series: 40},
+		)
+		current := createTestSnapshot(t, db, models.Snapshot{CollectedAt: testTime.Add(time.Hour)},
+			testMetric{service: "api", metric: "http_requests_total", series: 300},
+			testMetric{service: "api", metric: "up", series: 5},
+			testMetric{service: "worker", metric: "jobs_total", series: 80},
+			testMetric{service: "worker", metric: "queue_depth", series: 20},
+		)
+
+		repo := NewMetricsRepository(db)
+		names := func(metrics []models.TopMetric) []string {
+			var out []string
+			for _, m := range metrics {
+				out = append(out, m.MetricName)
+			}
+			return out
+		}
+
+		bySeries, err := repo.ListTop(ctx, current, TopMetricsOptions{MinSeries: 10, Limit: 3})
+		if err != nil {
+			t.Fatalf("ListTop: %v", err)
+		}
+		if got, want := names(bySeries), []string{"http_requests_total", "jobs_total", "queue_depth"}; !slices.Equal(got, want) {
+			t.Errorf("by series = %v, want %v", got, want)
+		}
+		if m := bySeries[0]; m.PreviousSeriesCount != 0 || m.SeriesDelta != 0 || m.New {
+			t.Errorf("metric without a comparison = %+v, want no growth fields", m)
+		}
+
+		byPercent, err := repo.ListTop(ctx, current, TopMetricsOptions{PreviousSnapshotID: previous, Sort: "growth_percent"})
+		if err != nil {
+			t.Fatalf("ListTop: %v", err)
+		}
+		if got, want := names(byPercent), []string{"jobs_total", "queue_depth", "http_requests_total", "up"}; !slices.Equal(got, want) {
+			t.Errorf("by growth percent = %v, want %v", got, want)
+		}
+		want := []struct {
+			previous, delta int
+			percent         float64
+			isNew           bool
+		}{
+			{40, 40, 100, false},
+			{0, 20, 100, true},
+			{200, 100, 50, false},
+			{10, -5, -50, false},
+		}
+		for i, w := range want {
+			m := byPercent[i]
+			if m.PreviousSeriesCount != w.previous || m.SeriesDelta != w.delta || m.SeriesDeltaPercent != w.percent || m.New != w.isNew {
+				t.Errorf("%s = %+v, want previous %d, delta %d, percent %v, new %v", m.MetricName, m, w.previous, w.delta, w.percent, w.isNew)
+			}
+		}
+
+		byGrowth, err := repo.ListTop(ctx, current, TopMetricsOptions{PreviousSnapshotID: previous, Sort: "growth"})
+		if err != nil {
+			t.Fatalf("ListTop: %v", err)
+		}
+		if got, want := names(byGrowth), []string{"http_requests_total", "jobs_total", "queue_depth", "up"}; !slices.Equal(got, want) {
+			t.Errorf("by growth = %v, want %v", got, want)
+		}
+
+		// An unknown snapshot lists nothing, so the handler has to tell it apart from an empty scan.
+		unknown, err := repo.ListTop(ctx, current+100, TopMetricsOptions{})
+		if err != nil {
+			t.Fatalf("ListTop: %v", err)
+		}
+		if len(unknown) != 0 {
+			t.Errorf("unknown snapshot lists %v", names(unknown))
+		}
+	})
+}
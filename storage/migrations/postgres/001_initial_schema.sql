@@ -0,0 +1,117 @@
+-- PostgreSQL schema, equivalent to sqlite migrations 001-012.
+-- Timestamps are stored as RFC 3339 text like on SQLite so both backends share their queries.
+
+CREATE TABLE IF NOT EXISTS scan_runs (
+    id BIGSERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'running',
+    error TEXT,
+    started_at TEXT NOT NULL,
+    finished_at TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_scan_runs_started ON scan_runs(started_at DESC);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    scan_run_id BIGINT REFERENCES scan_runs(id) ON DELETE SET NULL,
+    source TEXT NOT NULL DEFAULT '',
+    tenant TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'completed',
+    service_filter TEXT NOT NULL DEFAULT '',
+    profile TEXT NOT NULL DEFAULT '',
+    collected_at TEXT NOT NULL,
+    scan_duration_ms BIGINT,
+    total_services BIGINT NOT NULL DEFAULT 0,
+    total_series BIGINT NOT NULL DEFAULT 0,
+    baseline_name TEXT,
+    baseline_description TEXT NOT NULL DEFAULT '',
+    baseline_default BOOLEAN NOT NULL DEFAULT FALSE,
+    pinned_at TEXT,
+    UNIQUE(source, tenant, scan_run_id)
+);
+CREATE INDEX IF NOT EXISTS idx_snapshots_time ON snapshots(collected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_snapshots_scope_time ON snapshots(source, tenant, collected_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_snapshots_baseline_name ON snapshots(baseline_name);
+
+CREATE TABLE IF NOT EXISTS service_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    service_name TEXT NOT NULL,
+    total_series BIGINT NOT NULL DEFAULT 0,
+    metric_count BIGINT NOT NULL DEFAULT 0,
+    UNIQUE(snapshot_id, service_name)
+);
+CREATE INDEX IF NOT EXISTS idx_service_snapshots_name ON service_snapshots(service_name);
+CREATE INDEX IF NOT EXISTS idx_service_snapshots_name_snapshot ON service_snapshots(service_name, snapshot_id);
+
+CREATE TABLE IF NOT EXISTS metric_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    service_snapshot_id BIGINT NOT NULL REFERENCES service_snapshots(id) ON DELETE CASCADE,
+    metric_name TEXT NOT NULL,
+    series_count BIGINT NOT NULL DEFAULT 0,
+    label_count BIGINT NOT NULL DEFAULT 0,
+    UNIQUE(service_snapshot_id, metric_name)
+);
+CREATE INDEX IF NOT EXISTS idx_metric_snapshots_name ON metric_snapshots(metric_name);
+CREATE INDEX IF NOT EXISTS idx_metric_snapshots_name_service ON metric_snapshots(metric_name, service_snapshot_id);
+
+CREATE TABLE IF NOT EXISTS label_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    metric_snapshot_id BIGINT NOT NULL REFERENCES metric_snapshots(id) ON DELETE CASCADE,
+    label_name TEXT NOT NULL,
+    unique_values_count BIGINT NOT NULL DEFAULT 0,
+    sample_values TEXT,
+    series_without_label BIGINT,
+    UNIQUE(metric_snapshot_id, label_name)
+);
+
+CREATE TABLE IF NOT EXISTS snapshot_analyses (
+    id BIGSERIAL PRIMARY KEY,
+    current_snapshot_id BIGINT NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    previous_snapshot_id BIGINT NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    result TEXT,
+    tool_calls TEXT,
+    error TEXT,
+    created_at TEXT NOT NULL,
+    completed_at TEXT,
+    UNIQUE(current_snapshot_id, previous_snapshot_id)
+);
+CREATE INDEX IF NOT EXISTS idx_analyses_previous ON snapshot_analyses(previous_snapshot_id);
+CREATE INDEX IF NOT EXISTS idx_analyses_status ON snapshot_analyses(status);
+
+CREATE TABLE IF NOT EXISTS label_findings (
+    id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    service_name TEXT NOT NULL,
+    metric_name TEXT NOT NULL,
+    label_name TEXT NOT NULL,
+    rule TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    unique_values BIGINT NOT NULL DEFAULT 0,
+    previous_unique_values BIGINT NOT NULL DEFAULT 0,
+    pattern TEXT,
+    examples TEXT,
+    message TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_label_findings_snapshot ON label_findings(snapshot_id);
+
+CREATE TABLE IF NOT EXISTS tsdb_stats (
+    id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    stat TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value BIGINT NOT NULL DEFAULT 0,
+    UNIQUE(snapshot_id, stat, name)
+);
+
+-- metric_name is empty for a service-level failure, set for a failed label fetch.
+CREATE TABLE IF NOT EXISTS scan_failures (
+    id BIGSERIAL PRIMARY KEY,
+    scan_run_id BIGINT NOT NULL REFERENCES scan_runs(id) ON DELETE CASCADE,
+    snapshot_id BIGINT NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    service_name TEXT NOT NULL,
+    metric_name TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_scan_failures_run ON scan_failures(scan_run_id);
+CREATE INDEX IF NOT EXISTS idx_scan_failures_snapshot ON scan_failures(snapshot_id);
@@ -0,0 +1,3 @@
+-- Cross-snapshot lookups by name (history endpoints)
+CREATE INDEX IF NOT EXISTS idx_service_snapshots_name_snapshot ON service_snapshots(service_name, snapshot_id);
+CREATE INDEX IF NOT EXISTS idx_metric_snapshots_name_service ON metric_snapshots(metric_name, service_snapshot_id);
@@ -0,0 +1,16 @@
+-- Label explosion findings (deterministic detection pass after each scan)
+CREATE TABLE IF NOT EXISTS label_findings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snapshot_id INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    service_name TEXT NOT NULL,
+    metric_name TEXT NOT NULL,
+    label_name TEXT NOT NULL,
+    rule TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    unique_values INTEGER NOT NULL DEFAULT 0,
+    previous_unique_values INTEGER NOT NULL DEFAULT 0,
+    pattern TEXT,
+    examples TEXT,
+    message TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_label_findings_snapshot ON label_findings(snapshot_id);
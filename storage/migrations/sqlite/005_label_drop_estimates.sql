@@ -0,0 +1,2 @@
+-- Series count of the metric if the label were dropped (label-drop optimization suggestions)
+ALTER TABLE label_snapshots ADD COLUMN series_without_label INTEGER;
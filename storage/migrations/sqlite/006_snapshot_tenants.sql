@@ -0,0 +1,21 @@
+-- Tenant dimension on snapshots (one snapshot per tenant per scan).
+-- SQLite cannot alter a UNIQUE constraint, so the table is rebuilt;
+-- foreign keys are disabled while migrating so child rows are kept.
+CREATE TABLE snapshots_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant TEXT NOT NULL DEFAULT '',
+    collected_at TIMESTAMP NOT NULL,
+    scan_duration_ms INTEGER,
+    total_services INTEGER NOT NULL DEFAULT 0,
+    total_series INTEGER NOT NULL DEFAULT 0,
+    UNIQUE(tenant, collected_at)
+);
+
+INSERT INTO snapshots_new (id, collected_at, scan_duration_ms, total_services, total_series)
+SELECT id, collected_at, scan_duration_ms, total_services, total_series FROM snapshots;
+
+DROP TABLE snapshots;
+ALTER TABLE snapshots_new RENAME TO snapshots;
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_time ON snapshots(collected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_snapshots_tenant_time ON snapshots(tenant, collected_at DESC);
@@ -0,0 +1,21 @@
+-- Source dimension on snapshots (one snapshot per source and tenant per scan).
+-- Rebuilt like 006 to extend the UNIQUE constraint.
+CREATE TABLE snapshots_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL DEFAULT '',
+    tenant TEXT NOT NULL DEFAULT '',
+    collected_at TIMESTAMP NOT NULL,
+    scan_duration_ms INTEGER,
+    total_services INTEGER NOT NULL DEFAULT 0,
+    total_series INTEGER NOT NULL DEFAULT 0,
+    UNIQUE(source, tenant, collected_at)
+);
+
+INSERT INTO snapshots_new (id, tenant, collected_at, scan_duration_ms, total_services, total_series)
+SELECT id, tenant, collected_at, scan_duration_ms, total_services, total_series FROM snapshots;
+
+DROP TABLE snapshots;
+ALTER TABLE snapshots_new RENAME TO snapshots;
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_time ON snapshots(collected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_snapshots_scope_time ON snapshots(source, tenant, collected_at DESC);
@@ -0,0 +1,10 @@
+-- TSDB status overview per snapshot (tsdb scan mode)
+CREATE TABLE IF NOT EXISTS tsdb_stats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snapshot_id INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    stat TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value INTEGER NOT NULL DEFAULT 0,
+    UNIQUE(snapshot_id, stat, name)
+);
+CREATE INDEX IF NOT EXISTS idx_tsdb_stats_snapshot ON tsdb_stats(snapshot_id, stat);
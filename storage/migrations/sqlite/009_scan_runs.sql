@@ -0,0 +1,25 @@
+-- Persistent scan runs: status, error and the services and metrics that failed.
+CREATE TABLE IF NOT EXISTS scan_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    status TEXT NOT NULL DEFAULT 'running',
+    error TEXT,
+    started_at TEXT NOT NULL,
+    finished_at TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_scan_runs_started ON scan_runs(started_at DESC);
+
+-- metric_name is empty for a service-level failure, set for a failed label fetch.
+CREATE TABLE IF NOT EXISTS scan_failures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scan_run_id INTEGER NOT NULL REFERENCES scan_runs(id) ON DELETE CASCADE,
+    snapshot_id INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    service_name TEXT NOT NULL,
+    metric_name TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_scan_failures_run ON scan_failures(scan_run_id);
+CREATE INDEX IF NOT EXISTS idx_scan_failures_snapshot ON scan_failures(snapshot_id);
+
+-- Snapshots from before this migration have no run and are assumed complete.
+ALTER TABLE snapshots ADD COLUMN scan_run_id INTEGER REFERENCES scan_runs(id) ON DELETE SET NULL;
+ALTER TABLE snapshots ADD COLUMN status TEXT NOT NULL DEFAULT 'completed';
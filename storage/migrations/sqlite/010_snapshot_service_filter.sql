@@ -0,0 +1,2 @@
+-- Targeted scans cover only some services. service_filter describes which, and is empty for full scans.
+ALTER TABLE snapshots ADD COLUMN service_filter TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,25 @@
+-- Scan profile on snapshots. Profiles can scan the same source within one second, so
+-- snapshots are unique per scan run instead of per collection time. Rebuilt like 007.
+CREATE TABLE snapshots_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scan_run_id INTEGER REFERENCES scan_runs(id) ON DELETE SET NULL,
+    source TEXT NOT NULL DEFAULT '',
+    tenant TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'completed',
+    service_filter TEXT NOT NULL DEFAULT '',
+    profile TEXT NOT NULL DEFAULT '',
+    collected_at TIMESTAMP NOT NULL,
+    scan_duration_ms INTEGER,
+    total_services INTEGER NOT NULL DEFAULT 0,
+    total_series INTEGER NOT NULL DEFAULT 0,
+    UNIQUE(source, tenant, scan_run_id)
+);
+
+INSERT INTO snapshots_new (id, scan_run_id, source, tenant, status, service_filter, collected_at, scan_duration_ms, total_services, total_series)
+SELECT id, scan_run_id, source, tenant, status, service_filter, collected_at, scan_duration_ms, total_services, total_series FROM snapshots;
+
+DROP TABLE snapshots;
+ALTER TABLE snapshots_new RENAME TO snapshots;
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_time ON snapshots(collected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_snapshots_scope_time ON snapshots(source, tenant, collected_at DESC);
@@ -0,0 +1,7 @@
+-- Pinned baselines: named snapshots that retention cleanup keeps. The default baseline of a
+-- source and tenant is the comparison target for its diffs, analyses and notifications.
+ALTER TABLE snapshots ADD COLUMN baseline_name TEXT;
+ALTER TABLE snapshots ADD COLUMN baseline_description TEXT NOT NULL DEFAULT '';
+ALTER TABLE snapshots ADD COLUMN baseline_default INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE snapshots ADD COLUMN pinned_at TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_snapshots_baseline_name ON snapshots(baseline_name);
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// NewPostgres opens a PostgreSQL database and applies its migrations. Unlike SQLite it is safe
+// to share between several instances. maxOpenConns caps the connection pool; zero leaves it unlimited.
+func NewPostgres(dsn string, maxOpenConns int) (*DB, error) {
+	conn, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if maxOpenConns > 0 {
+		conn.SetMaxOpenConns(maxOpenConns)
+		conn.SetMaxIdleConns(maxOpenConns)
+	}
+	conn.SetConnMaxIdleTime(5 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	db := &DB{conn: conn, dialect: DialectPostgres}
+
+	if err := db.migrate(context.Background()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+// RetentionTier keeps one snapshot per Resolution, for each source and tenant, among the
+// snapshots up to MaxAge old that no earlier tier covers. A zero Resolution keeps them all.
+type RetentionTier struct {
+	MaxAge     time.Duration
+	Resolution time.Duration
+}
+
+// thinCandidate is a snapshot that a downsampling tier may delete.
+type thinCandidate struct {
+	id            int64
+	source        string
+	tenant        string
+	status        models.ScanRunStatus
+	serviceFilter string
+	collectedAt   time.Time
+	hasLabels     bool
+}
+
+// betterThan reports whether c is a better snapshot to keep for its bucket than o:
+// full over targeted, completed over partial, with label details, then the newest.
+func (c *thinCandidate) betterThan(o *thinCandidate) bool {
+	if (c.serviceFilter == "") != (o.serviceFilter == "") {
+		return c.serviceFilter == ""
+	}
+	if (c.status == models.ScanRunStatusCompleted) != (o.status == models.ScanRunStatusCompleted) {
+		return c.status == models.ScanRunStatusCompleted
+	}
+	if c.hasLabels != o.hasLabels {
+		return c.hasLabels
+	}
+	return c.collectedAt.After(o.collectedAt)
+}
+
+type thinBucket struct {
+	source string
+	tenant string
+	tier   int
+	start  time.Time
+}
+
+// thinSnapshots deletes all but the best snapshot of every Resolution bucket of the tiers.
+// Tiers are ordered by MaxAge; snapshots past the last tier are left to the expiry cutoff.
+func (db *DB) thinSnapshots(ctx context.Context, tiers []RetentionTier, now time.Time) (int64, error) {
+	first := -1
+	for i, tier := range tiers {
+		if tier.Resolution > 0 {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return 0, nil
+	}
+	var newerThan time.Duration
+	if first > 0 {
+		newerThan = tiers[first-1].MaxAge
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id, s.source, s.tenant, s.status, s.service_filter, s.collected_at,
+			EXISTS (
+				SELECT 1 FROM service_snapshots ss
+				JOIN metric_snapshots ms ON ms.service_snapshot_id = ss.id
+				WHERE ss.snapshot_id = s.id AND ms.label_count > 0
+			)
+		FROM snapshots s
+		WHERE s.collected_at < ? AND s.status != ? AND s.baseline_name IS NULL
+	`, now.Add(-newerThan).Format(time.RFC3339), models.ScanRunStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("list snapshots to thin: %w", err)
+	}
+
+	best := make(map[thinBucket]*thinCandidate)
+	var candidates []*thinCandidate
+	for rows.Next() {
+		var c thinCandidate
+		var collectedAt string
+		if err := rows.Scan(&c.id, &c.source, &c.tenant, &c.status, &c.serviceFilter, &collectedAt, &c.hasLabels); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		c.collectedAt, err = time.Parse(time.RFC3339, collectedAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		age := now.Sub(c.collectedAt)
+		tier := -1
+		for i := first; i < len(tiers); i++ {
+			if age <= tiers[i].MaxAge {
+				tier = i
+				break
+			}
+		}
+		if tier < 0 || tiers[tier].Resolution == 0 {
+			continue
+		}
+
+		key := thinBucket{source: c.source, tenant: c.tenant, tier: tier, start: c.collectedAt.Truncate(tiers[tier].Resolution)}
+		candidates = append(candidates, &c)
+		if cur, ok := best[key]; !ok || c.betterThan(cur) {
+			best[key] = &c
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	kept := make(map[int64]bool, len(best))
+	for _, c := range best {
+		kept[c.id] = true
+	}
+	var doomed []int64
+	for _, c := range candidates {
+		if !kept[c.id] {
+			doomed = append(doomed, c.id)
+		}
+	}
+	if len(doomed) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback snapshot thinning", "error", err)
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, "DELETE FROM snapshots WHERE id = ?")
+	if err != nil {
+		return 0, fmt.Errorf("prepare stmt: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range doomed {
+		if _, err := stmt.ExecContext(ctx, id); err != nil {
+			return 0, fmt.Errorf("delete snapshot %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(doomed)), nil
+}
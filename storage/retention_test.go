@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+func remainingSnapshots(t *testing.T, db *DB) []int64 {
+	t.Helper()
+	rows, err := db.QueryContext(context.Background(), "SELECT id FROM snapshots ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestThinSnapshots(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+		day := func(daysAgo, hour int) time.Time {
+			return now.AddDate(0, 0, -daysAgo).Add(time.Duration(hour) * time.Hour)
+		}
+		withLabels := testMetric{service: "api", metric: "http_requests_total", series: 10, labels: map[string]int{"path": 5}}
+		noLabels := testMetric{service: "api", metric: "http_requests_total", series: 10}
+
+		// Within the first tier every snapshot is kept.
+		recent1 := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(1, 8)}, noLabels)
+		recent2 := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(1, 9)}, noLabels)
+
+		// One day in the daily tier: the completed snapshot with labels wins over newer ones.
+		best := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(10, 10)}, withLabels)
+		createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(10, 12)}, noLabels)
+		createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(10, 14), Status: models.ScanRunStatusPartial}, withLabels)
+		createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(10, 15), ServiceFilter: "api"}, withLabels)
+
+		// Otherwise equal snapshots keep the newest.
+		createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(20, 8)}, noLabels)
+		newest := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(20, 9)}, noLabels)
+
+		// Tenants, sources and baselines are thinned separately or not at all.
+		tenant := createTestSnapshot(t, db, models.Snapshot{Tenant: "team-a", CollectedAt: day(20, 7)}, noLabels)
+		source := createTestSnapshot(t, db, models.Snapshot{Source: "staging", CollectedAt: day(20, 7)}, noLabels)
+		pinned := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(20, 6)}, noLabels)
+		if err := NewSnapshotsRepository(db).Pin(ctx, pinned, models.Baseline{Name: "pre-migration"}); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+
+		// Past the last tier snapshots are left to the expiry cutoff of Cleanup.
+		old1 := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(100, 8)}, noLabels)
+		old2 := createTestSnapshot(t, db, models.Snapshot{CollectedAt: day(100, 9)}, noLabels)
+
+		tiers := []RetentionTier{
+			{MaxAge: 7 * 24 * time.Hour},
+			{MaxAge: 90 * 24 * time.Hour, Resolution: 24 * time.Hour},
+		}
+		deleted, err := db.thinSnapshots(ctx, tiers, now)
+		if err != nil {
+			t.Fatalf("thinSnapshots: %v", err)
+		}
+		if deleted != 4 {
+			t.Errorf("deleted %d snapshots, want 4", deleted)
+		}
+
+		want := []int64{recent1, recent2, best, newest, tenant, source, pinned, old1, old2}
+		slices.Sort(want)
+		if got := remainingSnapshots(t, db); !slices.Equal(got, want) {
+			t.Errorf("remaining snapshots = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestThinSnapshotsWithoutResolution(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+		createTestSnapshot(t, db, models.Snapshot{CollectedAt: now.Add(-48 * time.Hour)})
+		createTestSnapshot(t, db, models.Snapshot{CollectedAt: now.Add(-47 * time.Hour)})
+
+		deleted, err := db.thinSnapshots(context.Background(), []RetentionTier{{MaxAge: 90 * 24 * time.Hour}}, now)
+		if err != nil {
+			t.Fatalf("thinSnapshots: %v", err)
+		}
+		if deleted != 0 {
+			t.Errorf("deleted %d snapshots without a downsampling tier, want 0", deleted)
+		}
+	})
+}
+
+func TestThinCandidateBetterThan(t *testing.T) {
+	at := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	base := thinCandidate{status: models.ScanRunStatusCompleted, collectedAt: at}
+
+	tests := []struct {
+		name string
+		c, o thinCandidate
+	}{
+		{"full over targeted", withCandidate(base, func(c *thinCandidate) { c.collectedAt = at.Add(-time.Hour) }), withCandidate(base, func(c *thinCandidate) { c.serviceFilter = "api" })},
+		{"completed over partial", withCandidate(base, func(c *thinCandidate) { c.collectedAt = at.Add(-time.Hour) }), withCandidate(base, func(c *thinCandidate) { c.status = models.ScanRunStatusPartial })},
+		{"labels over none", withCandidate(base, func(c *thinCandidate) { c.hasLabels = true; c.collectedAt = at.Add(-time.Hour) }), base},
+		{"newer", withCandidate(base, func(c *thinCandidate) { c.collectedAt = at.Add(time.Hour) }), base},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.c.betterThan(&tt.o) {
+				t.Error("betterThan = false, want true")
+			}
+			if tt.o.betterThan(&tt.c) {
+				t.Error("reverse betterThan = true, want false")
+			}
+		})
+	}
+}
+
+func withCandidate(c thinCandidate, change func(*thinCandidate)) thinCandidate {
+	change(&c)
+	return c
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+type ScanRunsRepository struct {
+	db *DB
+}
+
+func NewScanRunsRepository(db *DB) *ScanRunsRepository {
+	return &ScanRunsRepository{db: db}
+}
+
+// Create starts a run in the running state.
+func (r *ScanRunsRepository) Create(ctx context.Context, startedAt time.Time) (*models.ScanRun, error) {
+	id, err := r.db.insert(ctx,
+		"INSERT INTO scan_runs (status, started_at) VALUES (?, ?)",
+		models.ScanRunStatusRunning,
+		startedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ScanRun{
+		ID:        id,
+		Status:    models.ScanRunStatusRunning,
+		StartedAt: startedAt,
+	}, nil
+}
+
+// Finish records the final status and error of a run.
+func (r *ScanRunsRepository) Finish(ctx context.Context, id int64, status models.ScanRunStatus, errText string, finishedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE scan_runs SET status = ?, error = ?, finished_at = ? WHERE id = ?",
+		status,
+		errText,
+		finishedAt.Format(time.RFC3339),
+		id,
+	)
+	return err
+}
+
+// FailInterrupted marks runs left running by a previous process as failed, along with their snapshots.
+func (r *ScanRunsRepository) FailInterrupted(ctx context.Context) (int64, error) {
+	now := time.Now().Format(time.RFC3339)
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE scan_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?",
+		models.ScanRunStatusFailed,
+		"interrupted by restart",
+		now,
+		models.ScanRunStatusRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.db.ExecContext(ctx,
+		"UPDATE snapshots SET status = ? WHERE status = ?",
+		models.ScanRunStatusPartial,
+		models.ScanRunStatusRunning,
+	); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *ScanRunsRepository) AddFailures(ctx context.Context, failures []*models.ScanFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback scan failures batch", "error", err)
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO scan_failures (scan_run_id, snapshot_id, service_name, metric_name, reason)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare stmt: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, f := range failures {
+		if _, err := stmt.ExecContext(ctx, f.ScanRunID, f.SnapshotID, f.ServiceName, f.MetricName, f.Reason); err != nil {
+			return fmt.Errorf("insert failure for %s: %w", f.ServiceName, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *ScanRunsRepository) GetByID(ctx context.Context, id int64) (*models.ScanRun, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT id, status, error, started_at, finished_at
+		FROM scan_runs
+		WHERE id = ?
+	`, id)
+
+	var run models.ScanRun
+	var errText, finishedAt sql.NullString
+	var startedAt string
+	err := row.Scan(&run.ID, &run.Status, &errText, &startedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := scanRunTimes(&run, errText, startedAt, finishedAt); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *ScanRunsRepository) List(ctx context.Context, limit int) ([]models.ScanRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, status, error, started_at, finished_at
+		FROM scan_runs
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.ScanRun
+	for rows.Next() {
+		var run models.ScanRun
+		var errText, finishedAt sql.NullString
+		var startedAt string
+		if err := rows.Scan(&run.ID, &run.Status, &errText, &startedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		if err := scanRunTimes(&run, errText, startedAt, finishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// ListFailures returns the failures of a run. A non-zero snapshotID narrows them to that snapshot.
+func (r *ScanRunsRepository) ListFailures(ctx context.Context, runID, snapshotID int64) ([]models.ScanFailure, error) {
+	query := `
+		SELECT scan_run_id, snapshot_id, service_name, metric_name, reason
+		FROM scan_failures
+		WHERE scan_run_id = ?`
+	args := []interface{}{runID}
+	if snapshotID != 0 {
+		query += " AND snapshot_id = ?"
+		args = append(args, snapshotID)
+	}
+	query += " ORDER BY service_name, metric_name"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []models.ScanFailure
+	for rows.Next() {
+		var f models.ScanFailure
+		if err := rows.Scan(&f.ScanRunID, &f.SnapshotID, &f.ServiceName, &f.MetricName, &f.Reason); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+func scanRunTimes(run *models.ScanRun, errText sql.NullString, startedAt string, finishedAt sql.NullString) error {
+	run.Error = errText.String
+
+	var err error
+	run.StartedAt, err = time.Parse(time.RFC3339, startedAt)
+	if err != nil {
+		return err
+	}
+	if finishedAt.Valid {
+		t, err := time.Parse(time.RFC3339, finishedAt.String)
+		if err != nil {
+			return err
+		}
+		run.FinishedAt = &t
+	}
+	return nil
+}
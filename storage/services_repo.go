@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/illenko/whodidthis/models"
 )
@@ -23,7 +25,7 @@ func (r *ServicesRepository) Create(ctx context.Context, s *models.ServiceSnapsh
 		INSERT INTO service_snapshots (snapshot_id, service_name, total_series, metric_count)
 		VALUES (?, ?, ?, ?)
 	`
-	result, err := r.db.conn.ExecContext(ctx, query,
+	id, err := r.db.insert(ctx, query,
 		s.SnapshotID,
 		s.ServiceName,
 		s.TotalSeries,
@@ -32,11 +34,11 @@ func (r *ServicesRepository) Create(ctx context.Context, s *models.ServiceSnapsh
 	if err != nil {
 		return 0, fmt.Errorf("insert service snapshot: %w", err)
 	}
-	return result.LastInsertId()
+	return id, nil
 }
 
 func (r *ServicesRepository) CreateBatch(ctx context.Context, services []*models.ServiceSnapshot) error {
-	tx, err := r.db.conn.BeginTx(ctx, nil)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
@@ -70,6 +72,12 @@ type ServiceListOptions struct {
 	Search string
 }
 
+// Delete removes a service snapshot and, through cascading deletes, its metrics and labels.
+func (r *ServicesRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM service_snapshots WHERE id = ?", id)
+	return err
+}
+
 func (r *ServicesRepository) List(ctx context.Context, snapshotID int64, opts ServiceListOptions) ([]models.ServiceSnapshot, error) {
 	query := `
 		SELECT id, snapshot_id, service_name, total_series, metric_count
@@ -79,7 +87,7 @@ func (r *ServicesRepository) List(ctx context.Context, snapshotID int64, opts Se
 	args := []interface{}{snapshotID}
 
 	if opts.Search != "" {
-		query += " AND service_name LIKE ?"
+		query += " AND LOWER(service_name) LIKE LOWER(?)"
 		args = append(args, "%"+opts.Search+"%")
 	}
 
@@ -98,7 +106,7 @@ func (r *ServicesRepository) List(ctx context.Context, snapshotID int64, opts Se
 		}
 	}
 
-	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +130,7 @@ func (r *ServicesRepository) GetByName(ctx context.Context, snapshotID int64, na
 		WHERE snapshot_id = ? AND service_name = ?
 	`
 	var s models.ServiceSnapshot
-	err := r.db.conn.QueryRowContext(ctx, query, snapshotID, name).Scan(
+	err := r.db.QueryRowContext(ctx, query, snapshotID, name).Scan(
 		&s.ID, &s.SnapshotID, &s.ServiceName, &s.TotalSeries, &s.MetricCount,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -133,3 +141,46 @@ func (r *ServicesRepository) GetByName(ctx context.Context, snapshotID int64, na
 	}
 	return &s, nil
 }
+
+// History returns the service across snapshots in chronological order.
+// If limit > 0, only the most recent limit snapshots are returned.
+func (r *ServicesRepository) History(ctx context.Context, name string, filter SnapshotFilter, limit int) ([]models.ServiceHistoryPoint, error) {
+	where, filterArgs := filter.where("s")
+	query := `
+		SELECT s.id, s.source, s.tenant, s.collected_at, ss.total_series, ss.metric_count
+		FROM service_snapshots ss
+		JOIN snapshots s ON s.id = ss.snapshot_id
+		WHERE ss.service_name = ?` + where + `
+		ORDER BY s.collected_at DESC
+	`
+	args := append([]interface{}{name}, filterArgs...)
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.ServiceHistoryPoint
+	for rows.Next() {
+		var p models.ServiceHistoryPoint
+		var collectedAt string
+		if err := rows.Scan(&p.SnapshotID, &p.Source, &p.Tenant, &collectedAt, &p.TotalSeries, &p.MetricCount); err != nil {
+			return nil, err
+		}
+		if p.CollectedAt, err = time.Parse(time.RFC3339, collectedAt); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(points)
+	return points, nil
+}
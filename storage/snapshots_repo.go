@@ -4,11 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/illenko/whodidthis/models"
 )
 
+// ErrBaselineNameTaken is returned by Pin when another snapshot is pinned under the name.
+var ErrBaselineNameTaken = errors.New("baseline name already in use")
+
+// SnapshotFilter narrows snapshot queries to one source and/or tenant. Empty fields match every snapshot.
+type SnapshotFilter struct {
+	Source string
+	Tenant string
+}
+
+// where returns the SQL conditions for the filter, prefixed with AND, on the given snapshots table alias.
+func (f SnapshotFilter) where(alias string) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if f.Source != "" {
+		clause += " AND " + alias + ".source = ?"
+		args = append(args, f.Source)
+	}
+	if f.Tenant != "" {
+		clause += " AND " + alias + ".tenant = ?"
+		args = append(args, f.Tenant)
+	}
+	return clause, args
+}
+
 type SnapshotsRepository struct {
 	db *DB
 }
@@ -19,10 +45,24 @@ func NewSnapshotsRepository(db *DB) *SnapshotsRepository {
 
 func (r *SnapshotsRepository) Create(ctx context.Context, s *models.Snapshot) (int64, error) {
 	query := `
-		INSERT INTO snapshots (collected_at, scan_duration_ms, total_services, total_series)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO snapshots (scan_run_id, source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	result, err := r.db.conn.ExecContext(ctx, query,
+	status := s.Status
+	if status == "" {
+		status = models.ScanRunStatusCompleted
+	}
+	var scanRunID *int64
+	if s.ScanRunID != 0 {
+		scanRunID = &s.ScanRunID
+	}
+	id, err := r.db.insert(ctx, query,
+		scanRunID,
+		s.Source,
+		s.Tenant,
+		status,
+		s.ServiceFilter,
+		s.Profile,
 		s.CollectedAt.Format(time.RFC3339),
 		s.ScanDurationMs,
 		s.TotalServices,
@@ -31,16 +71,17 @@ func (r *SnapshotsRepository) Create(ctx context.Context, s *models.Snapshot) (i
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	return id, nil
 }
 
 func (r *SnapshotsRepository) Update(ctx context.Context, s *models.Snapshot) error {
 	query := `
 		UPDATE snapshots
-		SET scan_duration_ms = ?, total_services = ?, total_series = ?
+		SET status = ?, scan_duration_ms = ?, total_services = ?, total_series = ?
 		WHERE id = ?
 	`
-	_, err := r.db.conn.ExecContext(ctx, query,
+	_, err := r.db.ExecContext(ctx, query,
+		s.Status,
 		s.ScanDurationMs,
 		s.TotalServices,
 		s.TotalSeries,
@@ -49,33 +90,42 @@ func (r *SnapshotsRepository) Update(ctx context.Context, s *models.Snapshot) er
 	return err
 }
 
-func (r *SnapshotsRepository) GetLatest(ctx context.Context) (*models.Snapshot, error) {
+// GetLatest returns the most recent finished full snapshot; running, cancelled and targeted
+// snapshots hold only part of the services.
+func (r *SnapshotsRepository) GetLatest(ctx context.Context, filter SnapshotFilter) (*models.Snapshot, error) {
+	where, args := filter.where("s")
 	query := `
-		SELECT id, collected_at, scan_duration_ms, total_services, total_series
-		FROM snapshots
-		ORDER BY collected_at DESC
+		SELECT id, scan_run_id, source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series,
+			baseline_name, baseline_description, baseline_default, pinned_at
+		FROM snapshots s
+		WHERE status NOT IN ('running', 'cancelled') AND service_filter = ''` + where + `
+		ORDER BY collected_at DESC, id DESC
 		LIMIT 1
 	`
-	return r.scanOne(r.db.conn.QueryRowContext(ctx, query))
+	return r.scanOne(r.db.QueryRowContext(ctx, query, args...))
 }
 
 func (r *SnapshotsRepository) GetByID(ctx context.Context, id int64) (*models.Snapshot, error) {
 	query := `
-		SELECT id, collected_at, scan_duration_ms, total_services, total_series
+		SELECT id, scan_run_id, source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series,
+			baseline_name, baseline_description, baseline_default, pinned_at
 		FROM snapshots
 		WHERE id = ?
 	`
-	return r.scanOne(r.db.conn.QueryRowContext(ctx, query, id))
+	return r.scanOne(r.db.QueryRowContext(ctx, query, id))
 }
 
-func (r *SnapshotsRepository) List(ctx context.Context, limit int) ([]models.Snapshot, error) {
+func (r *SnapshotsRepository) List(ctx context.Context, filter SnapshotFilter, limit int) ([]models.Snapshot, error) {
+	where, args := filter.where("s")
 	query := `
-		SELECT id, collected_at, scan_duration_ms, total_services, total_series
-		FROM snapshots
-		ORDER BY collected_at DESC
+		SELECT id, scan_run_id, source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series,
+			baseline_name, baseline_description, baseline_default, pinned_at
+		FROM snapshots s
+		WHERE 1 = 1` + where + `
+		ORDER BY collected_at DESC, id DESC
 		LIMIT ?
 	`
-	rows, err := r.db.conn.QueryContext(ctx, query, limit)
+	rows, err := r.db.QueryContext(ctx, query, append(args, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -92,33 +142,174 @@ func (r *SnapshotsRepository) List(ctx context.Context, limit int) ([]models.Sna
 	return snapshots, rows.Err()
 }
 
-func (r *SnapshotsRepository) GetByDate(ctx context.Context, date time.Time) (*models.Snapshot, error) {
-	// Find snapshot closest to the given date (same day)
+// GetByDate returns the latest finished full snapshot collected on the day of date. Pass the
+// source and tenant in the filter; snapshots of different sources are not comparable.
+func (r *SnapshotsRepository) GetByDate(ctx context.Context, date time.Time, filter SnapshotFilter) (*models.Snapshot, error) {
 	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
+	where, filterArgs := filter.where("s")
 	query := `
-		SELECT id, collected_at, scan_duration_ms, total_services, total_series
-		FROM snapshots
+		SELECT id, scan_run_id, source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series,
+			baseline_name, baseline_description, baseline_default, pinned_at
+		FROM snapshots s
 		WHERE collected_at >= ? AND collected_at < ?
-		ORDER BY collected_at DESC
+			AND status NOT IN ('running', 'cancelled') AND service_filter = ''` + where + `
+		ORDER BY collected_at DESC, id DESC
 		LIMIT 1
 	`
-	return r.scanOne(r.db.conn.QueryRowContext(ctx, query,
-		startOfDay.Format(time.RFC3339),
-		endOfDay.Format(time.RFC3339),
-	))
+	args := append([]interface{}{startOfDay.Format(time.RFC3339), endOfDay.Format(time.RFC3339)}, filterArgs...)
+	return r.scanOne(r.db.QueryRowContext(ctx, query, args...))
 }
 
-func (r *SnapshotsRepository) GetNDaysAgo(ctx context.Context, days int) (*models.Snapshot, error) {
+func (r *SnapshotsRepository) GetNDaysAgo(ctx context.Context, days int, filter SnapshotFilter) (*models.Snapshot, error) {
 	targetDate := time.Now().AddDate(0, 0, -days)
-	return r.GetByDate(ctx, targetDate)
+	return r.GetByDate(ctx, targetDate, filter)
+}
+
+// GetPrevious returns the finished full snapshot of the same source and tenant collected right before
+// the given one, or nil if there is none.
+func (r *SnapshotsRepository) GetPrevious(ctx context.Context, id int64) (*models.Snapshot, error) {
+	query := `
+		SELECT s.id, s.scan_run_id, s.source, s.tenant, s.status, s.service_filter, s.profile, s.collected_at, s.scan_duration_ms, s.total_services, s.total_series,
+			s.baseline_name, s.baseline_description, s.baseline_default, s.pinned_at
+		FROM snapshots s
+		JOIN snapshots cur ON cur.id = ?
+		WHERE s.source = cur.source AND s.tenant = cur.tenant AND (s.collected_at < cur.collected_at OR (s.collected_at = cur.collected_at AND s.id < cur.id))
+			AND s.status NOT IN ('running', 'cancelled') AND s.service_filter = ''
+		ORDER BY s.collected_at DESC, s.id DESC
+		LIMIT 1
+	`
+	return r.scanOne(r.db.QueryRowContext(ctx, query, id))
+}
+
+// GetComparison returns the snapshot the given one is compared with by default: the default
+// baseline of its source and tenant when it was collected earlier, or else the snapshot before
+// it. It is nil if there is neither.
+func (r *SnapshotsRepository) GetComparison(ctx context.Context, id int64) (*models.Snapshot, error) {
+	query := `
+		SELECT s.id, s.scan_run_id, s.source, s.tenant, s.status, s.service_filter, s.profile, s.collected_at, s.scan_duration_ms, s.total_services, s.total_series,
+			s.baseline_name, s.baseline_description, s.baseline_default, s.pinned_at
+		FROM snapshots s
+		JOIN snapshots cur ON cur.id = ?
+		WHERE s.source = cur.source AND s.tenant = cur.tenant AND s.collected_at < cur.collected_at AND s.baseline_default = TRUE
+	`
+	baseline, err := r.scanOne(r.db.QueryRowContext(ctx, query, id))
+	if err != nil || baseline != nil {
+		return baseline, err
+	}
+	return r.GetPrevious(ctx, id)
+}
+
+// ListBaselines returns the pinned snapshots, newest first.
+func (r *SnapshotsRepository) ListBaselines(ctx context.Context, filter SnapshotFilter) ([]models.Snapshot, error) {
+	where, args := filter.where("s")
+	query := `
+		SELECT id, scan_run_id, source, tenant, status, service_filter, profile, collected_at, scan_duration_ms, total_services, total_series,
+			baseline_name, baseline_description, baseline_default, pinned_at
+		FROM snapshots s
+		WHERE baseline_name IS NOT NULL` + where + `
+		ORDER BY collected_at DESC, id DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []models.Snapshot
+	for rows.Next() {
+		s, err := r.scanFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *s)
+	}
+	return snapshots, rows.Err()
+}
+
+// Pin makes a snapshot a named baseline, or updates its baseline. A default baseline replaces
+// the previous default of the snapshot's source and tenant.
+func (r *SnapshotsRepository) Pin(ctx context.Context, id int64, b models.Baseline) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback baseline pin", "error", err)
+		}
+	}()
+
+	var taken int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM snapshots WHERE baseline_name = ? AND id != ?", b.Name, id,
+	).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrBaselineNameTaken
+	}
+
+	if b.Default {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE snapshots SET baseline_default = FALSE
+			WHERE id != ? AND (source, tenant) = (SELECT source, tenant FROM snapshots WHERE id = ?)
+		`, id, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE snapshots
+		SET baseline_name = ?, baseline_description = ?, baseline_default = ?, pinned_at = COALESCE(pinned_at, ?)
+		WHERE id = ?
+	`, b.Name, b.Description, b.Default, b.PinnedAt.Format(time.RFC3339), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Unpin removes the baseline of a snapshot, leaving it to retention cleanup again.
+func (r *SnapshotsRepository) Unpin(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE snapshots
+		SET baseline_name = NULL, baseline_description = '', baseline_default = FALSE, pinned_at = NULL
+		WHERE id = ?
+	`, id)
+	return err
+}
+
+// ListScopes returns each distinct source and tenant pair that has snapshots.
+func (r *SnapshotsRepository) ListScopes(ctx context.Context) ([]SnapshotFilter, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT DISTINCT source, tenant FROM snapshots ORDER BY source, tenant")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scopes []SnapshotFilter
+	for rows.Next() {
+		var scope SnapshotFilter
+		if err := rows.Scan(&scope.Source, &scope.Tenant); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, rows.Err()
+}
+
+// Delete removes a snapshot and, through cascading deletes, everything collected for it.
+func (r *SnapshotsRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM snapshots WHERE id = ?", id)
+	return err
 }
 
 func (r *SnapshotsRepository) DeleteOlderThan(ctx context.Context, days int) (int64, error) {
 	cutoff := time.Now().AddDate(0, 0, -days)
-	result, err := r.db.conn.ExecContext(ctx,
-		"DELETE FROM snapshots WHERE collected_at < ?",
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM snapshots WHERE collected_at < ? AND baseline_name IS NULL",
 		cutoff.Format(time.RFC3339),
 	)
 	if err != nil {
@@ -130,9 +321,13 @@ func (r *SnapshotsRepository) DeleteOlderThan(ctx context.Context, days int) (in
 func (r *SnapshotsRepository) scanOne(row *sql.Row) (*models.Snapshot, error) {
 	var s models.Snapshot
 	var collectedAt string
+	var scanRunID sql.NullInt64
 	var scanDuration sql.NullInt64
 
-	err := row.Scan(&s.ID, &collectedAt, &scanDuration, &s.TotalServices, &s.TotalSeries)
+	var b baselineColumns
+
+	err := row.Scan(&s.ID, &scanRunID, &s.Source, &s.Tenant, &s.Status, &s.ServiceFilter, &s.Profile, &collectedAt, &scanDuration, &s.TotalServices, &s.TotalSeries,
+		&b.name, &b.description, &b.isDefault, &b.pinnedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -144,18 +339,26 @@ func (r *SnapshotsRepository) scanOne(row *sql.Row) (*models.Snapshot, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.ScanRunID = scanRunID.Int64
 	if scanDuration.Valid {
 		s.ScanDurationMs = int(scanDuration.Int64)
 	}
+	if s.Baseline, err = b.baseline(); err != nil {
+		return nil, err
+	}
 	return &s, nil
 }
 
 func (r *SnapshotsRepository) scanFromRows(rows *sql.Rows) (*models.Snapshot, error) {
 	var s models.Snapshot
 	var collectedAt string
+	var scanRunID sql.NullInt64
 	var scanDuration sql.NullInt64
 
-	err := rows.Scan(&s.ID, &collectedAt, &scanDuration, &s.TotalServices, &s.TotalSeries)
+	var b baselineColumns
+
+	err := rows.Scan(&s.ID, &scanRunID, &s.Source, &s.Tenant, &s.Status, &s.ServiceFilter, &s.Profile, &collectedAt, &scanDuration, &s.TotalServices, &s.TotalSeries,
+		&b.name, &b.description, &b.isDefault, &b.pinnedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -164,8 +367,39 @@ func (r *SnapshotsRepository) scanFromRows(rows *sql.Rows) (*models.Snapshot, er
 	if err != nil {
 		return nil, err
 	}
+	s.ScanRunID = scanRunID.Int64
 	if scanDuration.Valid {
 		s.ScanDurationMs = int(scanDuration.Int64)
 	}
+	if s.Baseline, err = b.baseline(); err != nil {
+		return nil, err
+	}
 	return &s, nil
 }
+
+// baselineColumns holds the pin columns of a snapshot row; name is NULL for unpinned snapshots.
+type baselineColumns struct {
+	name        sql.NullString
+	description string
+	isDefault   bool
+	pinnedAt    sql.NullString
+}
+
+func (b baselineColumns) baseline() (*models.Baseline, error) {
+	if !b.name.Valid {
+		return nil, nil
+	}
+	baseline := &models.Baseline{
+		Name:        b.name.String,
+		Description: b.description,
+		Default:     b.isDefault,
+	}
+	if b.pinnedAt.Valid {
+		t, err := time.Parse(time.RFC3339, b.pinnedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		baseline.PinnedAt = t
+	}
+	return baseline, nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+func TestSnapshotsGetByDate(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		at := func(h int) time.Time { return testTime.Add(time.Duration(h) * time.Hour) }
+
+		teamA := createTestSnapshot(t, db, models.Snapshot{Tenant: "team-a", CollectedAt: at(1)})
+		teamB := createTestSnapshot(t, db, models.Snapshot{Tenant: "team-b", CollectedAt: at(2)})
+		createTestSnapshot(t, db, models.Snapshot{Tenant: "team-a", CollectedAt: at(3), Status: models.ScanRunStatusRunning})
+		createTestSnapshot(t, db, models.Snapshot{Tenant: "team-a", CollectedAt: at(4), Status: models.ScanRunStatusCancelled})
+		createTestSnapshot(t, db, models.Snapshot{Tenant: "team-a", CollectedAt: at(5), ServiceFilter: "api"})
+		createTestSnapshot(t, db, models.Snapshot{Tenant: "team-a", CollectedAt: at(-24)})
+		prod := createTestSnapshot(t, db, models.Snapshot{Source: "prod", Tenant: "team-a", CollectedAt: at(0)})
+		staging := createTestSnapshot(t, db, models.Snapshot{Source: "staging", Tenant: "team-a", CollectedAt: at(-1)})
+
+		repo := NewSnapshotsRepository(db)
+		tests := []struct {
+			name   string
+			filter SnapshotFilter
+			want   int64
+		}{
+			{"tenant a skips running, cancelled and targeted", SnapshotFilter{Tenant: "team-a"}, teamA},
+			{"source", SnapshotFilter{Source: "prod", Tenant: "team-a"}, prod},
+			{"other source", SnapshotFilter{Source: "staging"}, staging},
+			{"tenant b", SnapshotFilter{Tenant: "team-b"}, teamB},
+			{"unknown tenant", SnapshotFilter{Tenant: "team-c"}, 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s, err := repo.GetByDate(ctx, testTime, tt.filter)
+				if err != nil {
+					t.Fatalf("GetByDate: %v", err)
+				}
+				var got int64
+				if s != nil {
+					got = s.ID
+				}
+				if got != tt.want {
+					t.Errorf("got snapshot %d, want %d", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestSnapshotsBaselines(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		repo := NewSnapshotsRepository(db)
+		at := func(h int) time.Time { return testTime.Add(time.Duration(h) * time.Hour) }
+
+		s1 := createTestSnapshot(t, db, models.Snapshot{Source: "prod", CollectedAt: at(1)})
+		s2 := createTestSnapshot(t, db, models.Snapshot{Source: "prod", CollectedAt: at(2)})
+		s3 := createTestSnapshot(t, db, models.Snapshot{Source: "prod", CollectedAt: at(3)})
+		staging := createTestSnapshot(t, db, models.Snapshot{Source: "staging", CollectedAt: at(1)})
+
+		comparison := func(id int64) int64 {
+			t.Helper()
+			s, err := repo.GetComparison(ctx, id)
+			if err != nil {
+				t.Fatalf("GetComparison: %v", err)
+			}
+			if s == nil {
+				return 0
+			}
+			return s.ID
+		}
+		pin := func(id int64, name string, isDefault bool) error {
+			return repo.Pin(ctx, id, models.Baseline{Name: name, Default: isDefault, PinnedAt: testTime})
+		}
+
+		if got := comparison(s3); got != s2 {
+			t.Errorf("comparison without a baseline = %d, want the previous snapshot %d", got, s2)
+		}
+
+		if err := pin(s1, "before-upgrade", true); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if got := comparison(s3); got != s1 {
+			t.Errorf("comparison = %d, want the default baseline %d", got, s1)
+		}
+
+		// A new default of the same source replaces the old one; other sources keep theirs.
+		if err := pin(staging, "staging", true); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if err := pin(s2, "after-upgrade", true); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if got := comparison(s3); got != s2 {
+			t.Errorf("comparison = %d, want the new default baseline %d", got, s2)
+		}
+		// Snapshots collected before the baseline, or the baseline itself, are not compared with it.
+		if got := comparison(s2); got != s1 {
+			t.Errorf("comparison of the baseline = %d, want the previous snapshot %d", got, s1)
+		}
+		if got := comparison(s1); got != 0 {
+			t.Errorf("comparison of a snapshot older than the baseline = %d, want none", got)
+		}
+
+		if err := pin(s3, "before-upgrade", false); !errors.Is(err, ErrBaselineNameTaken) {
+			t.Errorf("Pin with a taken name = %v, want ErrBaselineNameTaken", err)
+		}
+
+		baselines, err := repo.ListBaselines(ctx, SnapshotFilter{Source: "prod"})
+		if err != nil {
+			t.Fatalf("ListBaselines: %v", err)
+		}
+		if len(baselines) != 2 || baselines[0].ID != s2 || baselines[1].ID != s1 {
+			t.Fatalf("baselines = %+v, want %d and %d", baselines, s2, s1)
+		}
+		if b := baselines[0].Baseline; b == nil || b.Name != "after-upgrade" || !b.Default || !b.PinnedAt.Equal(testTime) {
+			t.Errorf("baseline of %d = %+v", s2, b)
+		}
+		if b := baselines[1].Baseline; b == nil || b.Default {
+			t.Errorf("baseline of %d = %+v, want it no longer default", s1, b)
+		}
+
+		stagingSnapshot, err := repo.GetByID(ctx, staging)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if b := stagingSnapshot.Baseline; b == nil || !b.Default {
+			t.Errorf("staging baseline = %+v, want it still default", b)
+		}
+
+		if err := repo.Unpin(ctx, s2); err != nil {
+			t.Fatalf("Unpin: %v", err)
+		}
+		if got := comparison(s3); got != s2 {
+			t.Errorf("comparison after unpinning = %d, want the previous snapshot %d", got, s2)
+		}
+	})
+}
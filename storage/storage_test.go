@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+type testDialect struct {
+	dialect Dialect
+	open    func(t *testing.T) *DB // opens an empty, migrated database
+}
+
+// testDialects are the databases every storage test runs against. The integration build tag
+// adds PostgreSQL, see postgres_test.go.
+var testDialects = []testDialect{
+	{DialectSQLite, openTestSQLite},
+}
+
+func openTestSQLite(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// forEachDialect runs fn as a subtest against an empty database of every test dialect.
+func forEachDialect(t *testing.T, fn func(t *testing.T, db *DB)) {
+	t.Helper()
+	for _, d := range testDialects {
+		t.Run(string(d.dialect), func(t *testing.T) {
+			fn(t, d.open(t))
+		})
+	}
+}
+
+type testMetric struct {
+	service string
+	metric  string
+	series  int
+	labels  map[string]int
+}
+
+var testTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// createTestSnapshot stores s with the given metrics, grouping them into services, and returns its ID.
+func createTestSnapshot(t *testing.T, db *DB, s models.Snapshot, metrics ...testMetric) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	if s.CollectedAt.IsZero() {
+		s.CollectedAt = testTime
+	}
+	for _, m := range metrics {
+		s.TotalSeries += int64(m.series)
+	}
+	snapshotID, err := NewSnapshotsRepository(db).Create(ctx, &s)
+	if err != nil {
+		t.Fatalf("create snapshot: %v", err)
+	}
+
+	services := NewServicesRepository(db)
+	serviceIDs := make(map[string]int64)
+	for _, m := range metrics {
+		if _, ok := serviceIDs[m.service]; ok {
+			continue
+		}
+		svc := &models.ServiceSnapshot{SnapshotID: snapshotID, ServiceName: m.service}
+		for _, o := range metrics {
+			if o.service == m.service {
+				svc.TotalSeries += o.series
+				svc.MetricCount++
+			}
+		}
+		if serviceIDs[m.service], err = services.Create(ctx, svc); err != nil {
+			t.Fatalf("create service: %v", err)
+		}
+	}
+
+	for _, m := range metrics {
+		metricID, err := NewMetricsRepository(db).Create(ctx, &models.MetricSnapshot{
+			ServiceSnapshotID: serviceIDs[m.service],
+			MetricName:        m.metric,
+			SeriesCount:       m.series,
+			LabelCount:        len(m.labels),
+		})
+		if err != nil {
+			t.Fatalf("create metric: %v", err)
+		}
+		for name, values := range m.labels {
+			if _, err := NewLabelsRepository(db).Create(ctx, &models.LabelSnapshot{
+				MetricSnapshotID:  metricID,
+				LabelName:         name,
+				UniqueValuesCount: values,
+			}); err != nil {
+				t.Fatalf("create label: %v", err)
+			}
+		}
+	}
+	return snapshotID
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/illenko/whodidthis/models"
+)
+
+const (
+	statHeadSeries         = "head_series"
+	statSeriesByMetricName = "series_count_by_metric_name"
+	statLabelValuesByLabel = "label_value_count_by_label_name"
+	statSeriesByLabelValue = "series_count_by_label_value_pair"
+	statMemoryBytesByLabel = "memory_in_bytes_by_label_name"
+)
+
+type TSDBStatsRepository struct {
+	db *DB
+}
+
+func NewTSDBStatsRepository(db *DB) *TSDBStatsRepository {
+	return &TSDBStatsRepository{db: db}
+}
+
+// Create stores the TSDB status overview of a snapshot.
+func (r *TSDBStatsRepository) Create(ctx context.Context, o *models.TSDBOverview) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback tsdb stats batch", "error", err)
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO tsdb_stats (snapshot_id, stat, name, value)
+		VALUES (?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare stmt: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, o.SnapshotID, statHeadSeries, "", o.HeadSeries); err != nil {
+		return fmt.Errorf("insert head series: %w", err)
+	}
+
+	lists := []struct {
+		stat  string
+		stats []models.TSDBStat
+	}{
+		{statSeriesByMetricName, o.SeriesCountByMetricName},
+		{statLabelValuesByLabel, o.LabelValueCountByLabelName},
+		{statSeriesByLabelValue, o.SeriesCountByLabelValuePair},
+		{statMemoryBytesByLabel, o.MemoryInBytesByLabelName},
+	}
+	for _, list := range lists {
+		for _, s := range list.stats {
+			if _, err := stmt.ExecContext(ctx, o.SnapshotID, list.stat, s.Name, s.Value); err != nil {
+				return fmt.Errorf("insert %s %s: %w", list.stat, s.Name, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the TSDB status overview of a snapshot, or nil if none was recorded.
+func (r *TSDBStatsRepository) Get(ctx context.Context, snapshotID int64) (*models.TSDBOverview, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT stat, name, value
+		FROM tsdb_stats
+		WHERE snapshot_id = ?
+		ORDER BY stat, value DESC, name
+	`, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	o := &models.TSDBOverview{
+		SnapshotID:                  snapshotID,
+		SeriesCountByMetricName:     []models.TSDBStat{},
+		LabelValueCountByLabelName:  []models.TSDBStat{},
+		SeriesCountByLabelValuePair: []models.TSDBStat{},
+		MemoryInBytesByLabelName:    []models.TSDBStat{},
+	}
+	found := false
+	for rows.Next() {
+		var stat string
+		var s models.TSDBStat
+		if err := rows.Scan(&stat, &s.Name, &s.Value); err != nil {
+			return nil, err
+		}
+		found = true
+
+		switch stat {
+		case statHeadSeries:
+			o.HeadSeries = s.Value
+		case statSeriesByMetricName:
+			o.SeriesCountByMetricName = append(o.SeriesCountByMetricName, s)
+		case statLabelValuesByLabel:
+			o.LabelValueCountByLabelName = append(o.LabelValueCountByLabelName, s)
+		case statSeriesByLabelValue:
+			o.SeriesCountByLabelValuePair = append(o.SeriesCountByLabelValuePair, s)
+		case statMemoryBytesByLabel:
+			o.MemoryInBytesByLabelName = append(o.MemoryInBytesByLabelName, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return o, nil
+}
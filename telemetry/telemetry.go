@@ -0,0 +1,98 @@
+// Package telemetry holds whodidthis' own operational metrics and the registry served on /metrics.
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "whodidthis"
+
+// Registry is shared by the internal metrics below and the cardinality exporter.
+var Registry = prometheus.NewRegistry()
+
+var (
+	ScanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of full scans.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+	})
+	ScansTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scans_total",
+		Help:      "Scans by result (success, failed).",
+	}, []string{"result"})
+	ServicesScannedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scan_services_total",
+		Help:      "Services scanned by result (success, failed).",
+	}, []string{"result"})
+
+	PrometheusRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "prometheus_request_duration_seconds",
+		Help:      "Latency of requests to the Prometheus API by method, including reading the response body.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method"})
+	PrometheusRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prometheus_request_errors_total",
+		Help:      "Failed requests to the Prometheus API by method (transport errors and non-2xx responses).",
+	}, []string{"method"})
+	PrometheusResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "prometheus_response_size_bytes",
+		Help:      "Size of Prometheus API response bodies by method.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"method"})
+	PrometheusRequestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prometheus_request_retries_total",
+		Help:      "Retried requests to the Prometheus API by method.",
+	}, []string{"method"})
+	PrometheusCircuitOpen = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prometheus_circuit_breaker_open_total",
+		Help:      "Times a Prometheus circuit breaker opened after consecutive failures.",
+	})
+
+	StorageWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_write_duration_seconds",
+		Help:      "Latency of database writes during scans by operation.",
+		Buckets:   []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"operation"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of API requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ScanDuration,
+		ScansTotal,
+		ServicesScannedTotal,
+		PrometheusRequestDuration,
+		PrometheusRequestErrors,
+		PrometheusResponseSize,
+		PrometheusRequestRetries,
+		PrometheusCircuitOpen,
+		StorageWriteDuration,
+		HTTPRequestDuration,
+	)
+}
+
+// Handler serves everything registered in Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -6,7 +6,7 @@ import { Router } from './components/Router'
 
 function App() {
   const [route, setRoute] = useState<Route>(parseRoute)
-  const { scanStatus, triggerScan } = useScanStatus()
+  const { scanStatus, triggerScan, cancelScan } = useScanStatus()
 
   useEffect(() => {
     const onHashChange = () => setRoute(parseRoute())
@@ -18,7 +18,7 @@ function App() {
     <div className="min-h-screen bg-white dark:bg-gray-900 transition-colors">
       <Header />
       <main className="max-w-6xl mx-auto px-6 py-8">
-        <Router route={route} scanStatus={scanStatus} onScan={triggerScan} />
+        <Router route={route} scanStatus={scanStatus} onScan={triggerScan} onCancelScan={cancelScan} />
       </main>
     </div>
   )
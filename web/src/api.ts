@@ -3,10 +3,24 @@ import { API_BASE_URL, DEFAULT_SCANS_LIMIT } from './lib/constants'
 // Core types matching backend models
 export interface Scan {
   id: number
+  source?: string
+  tenant?: string
+  status: 'running' | 'completed' | 'partial' | 'failed' | 'cancelled'
+  scan_run_id?: number
+  service_filter?: string
+  profile?: string
   collected_at: string
   total_services: number
   total_series: number
   duration_ms: number
+  baseline?: Baseline
+}
+
+export interface Baseline {
+  name: string
+  description?: string
+  default: boolean
+  pinned_at: string
 }
 
 export interface Service {
@@ -39,7 +53,9 @@ export interface ScanStatus {
   last_scan_at: string
   last_duration: string
   last_error: string
+  profile?: string
   next_scan_at: string
+  next_profile?: string
   total_services: number
   total_series: number
 }
@@ -108,6 +124,7 @@ export const api = {
   // Scan control
   getScanStatus: () => fetchJSON<ScanStatus>(`${API_BASE_URL}/scan/status`),
   triggerScan: () => fetch(`${API_BASE_URL}/scan`, { method: 'POST' }),
+  cancelScan: () => fetch(`${API_BASE_URL}/scan`, { method: 'DELETE' }),
 
   // Scans (snapshots)
   getScans: (limit = DEFAULT_SCANS_LIMIT) => fetchJSON<Scan[]>(`${API_BASE_URL}/scans?limit=${limit}`),
@@ -10,12 +10,13 @@ interface RouterProps {
   route: Route
   scanStatus: ScanStatus | null
   onScan: () => void
+  onCancelScan: () => void
 }
 
-export function Router({ route, scanStatus, onScan }: RouterProps) {
+export function Router({ route, scanStatus, onScan, onCancelScan }: RouterProps) {
   switch (route.page) {
     case 'scans':
-      return <ScansPage scanStatus={scanStatus} onScan={onScan} />
+      return <ScansPage scanStatus={scanStatus} onScan={onScan} onCancelScan={onCancelScan} />
     case 'services':
       return <ServicesPage scanId={route.scanId} />
     case 'metrics':
@@ -34,5 +34,16 @@ export function useScanStatus() {
     }
   }, [loadScanStatus])
 
-  return { scanStatus, error, triggerScan, reload: loadScanStatus }
+  const cancelScan = useCallback(async () => {
+    try {
+      await api.cancelScan()
+      await loadScanStatus()
+      setError(null)
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to cancel scan')
+      console.error('Failed to cancel scan:', err)
+    }
+  }, [loadScanStatus])
+
+  return { scanStatus, error, triggerScan, cancelScan, reload: loadScanStatus }
 }
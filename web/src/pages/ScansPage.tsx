@@ -13,9 +13,10 @@ import { Button } from '../components/Button'
 interface ScansPageProps {
   scanStatus: ScanStatus | null
   onScan: () => void
+  onCancelScan: () => void
 }
 
-export function ScansPage({ scanStatus, onScan }: ScansPageProps) {
+export function ScansPage({ scanStatus, onScan, onCancelScan }: ScansPageProps) {
   const [scans, setScans] = useState<Scan[]>([])
   const [selectedScanId, setSelectedScanId] = useState<number | null>(null)
   const [services, setServices] = useState<Service[]>([])
@@ -97,7 +98,7 @@ export function ScansPage({ scanStatus, onScan }: ScansPageProps) {
 
   return (
     <div className="space-y-6">
-      <ScanStatusBanner status={scanStatus} onScan={onScan} />
+      <ScanStatusBanner status={scanStatus} onScan={onScan} onCancel={onCancelScan} />
 
       <div className="flex items-center justify-between flex-wrap gap-4">
         <div className="flex items-center gap-4 flex-wrap">
@@ -108,7 +109,7 @@ export function ScansPage({ scanStatus, onScan }: ScansPageProps) {
           >
             {scans.map((scan) => (
               <option key={scan.id} value={scan.id}>
-                Snapshot #{scan.id} — {formatDate(scan.collected_at)}
+                Snapshot #{scan.id}{scan.source || scan.tenant ? ` [${[scan.source, scan.tenant].filter(Boolean).join('/')}]` : ''} — {formatDate(scan.collected_at)}{scan.profile && scan.profile !== 'default' ? ` · ${scan.profile}` : ''}{scan.service_filter ? ` (targeted: ${scan.service_filter})` : ''}{scan.status && scan.status !== 'completed' ? ` (${scan.status})` : ''}{scan.baseline ? ` — baseline: ${scan.baseline.name}` : ''}
               </option>
             ))}
           </Select>
@@ -159,7 +160,7 @@ function formatScanProgress(progress: ScanProgress | null): string {
   }
 }
 
-function ScanStatusBanner({ status, onScan }: { status: ScanStatus | null; onScan: () => void }) {
+function ScanStatusBanner({ status, onScan, onCancel }: { status: ScanStatus | null; onScan: () => void; onCancel: () => void }) {
   if (!status) return null
 
   return (
@@ -175,13 +176,14 @@ function ScanStatusBanner({ status, onScan }: { status: ScanStatus | null; onSca
               </div>
             </div>
           </div>
+          <Button onClick={onCancel} size="sm" variant="secondary" aria-label="Cancel the running scan">Cancel</Button>
         </div>
       ) : (
         <div className="flex items-center justify-between flex-wrap gap-4">
           <div className="flex items-center gap-6 flex-wrap">
             <StatusItem label="Last scan" value={status.last_scan_at ? formatDate(status.last_scan_at) : 'Never'} />
             {status.last_duration && <StatusItem label="Duration" value={status.last_duration} />}
-            {status.next_scan_at && <StatusItem label="Next scan" value={formatDate(status.next_scan_at)} />}
+            {status.next_scan_at && <StatusItem label="Next scan" value={formatDate(status.next_scan_at) + (status.next_profile && status.next_profile !== 'default' ? ` (${status.next_profile})` : '')} />}
             {status.total_services > 0 && <StatusItem label="Services" value={formatNumber(status.total_services)} />}
             {status.total_series > 0 && <StatusItem label="Series" value={formatNumber(status.total_series)} />}
           </div>